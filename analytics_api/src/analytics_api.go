@@ -0,0 +1,268 @@
+package main
+
+// analytics_api.go is the read side of the historical reporting pipeline:
+// it accepts POST /analytics/query, maps a small fixed set of named report
+// types to parameterized Athena SQL against the Parquet table
+// metadata_parquet_transform maintains, and returns the result rows as
+// JSON. Only the named reports below are reachable - there is no raw-SQL
+// passthrough - so a client can never query anything but the metadata
+// export's own columns.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/athena"
+)
+
+// QueryRequest is the POST /analytics/query body. Params is interpreted
+// differently per ReportType - see buildQuery.
+type QueryRequest struct {
+	ReportType string            `json:"reportType"`
+	Params     map[string]string `json:"params"`
+}
+
+// QueryResponse carries the Athena result set back as generic rows so the
+// caller doesn't need a per-report-type response shape.
+type QueryResponse struct {
+	ReportType string              `json:"reportType"`
+	Columns    []string            `json:"columns"`
+	Rows       []map[string]string `json:"rows"`
+	Timestamp  string              `json:"timestamp"`
+}
+
+var (
+	athenaClient      *athena.Athena
+	analyticsDatabase string
+	analyticsTable    string
+	analyticsBucket   string
+)
+
+const athenaPollInterval = 1 * time.Second
+const athenaMaxPolls = 30
+
+func init() {
+	sess := session.Must(session.NewSession())
+	athenaClient = athena.New(sess)
+
+	analyticsDatabase = os.Getenv("ANALYTICS_GLUE_DATABASE")
+	analyticsTable = os.Getenv("ANALYTICS_GLUE_TABLE")
+	if analyticsTable == "" {
+		analyticsTable = "file_metadata"
+	}
+	analyticsBucket = os.Getenv("ANALYTICS_EXPORT_BUCKET")
+}
+
+func main() {
+	lambda.Start(handleRequest)
+}
+
+func handleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	headers := map[string]string{
+		"Content-Type":                "application/json",
+		"Access-Control-Allow-Origin": "*",
+	}
+
+	if request.HTTPMethod == "OPTIONS" {
+		return events.APIGatewayProxyResponse{StatusCode: 200, Headers: headers, Body: ""}, nil
+	}
+
+	if analyticsDatabase == "" || analyticsBucket == "" {
+		return errorResponse(headers, 500, "ANALYTICS_GLUE_DATABASE and ANALYTICS_EXPORT_BUCKET must both be set")
+	}
+
+	var body QueryRequest
+	if err := json.Unmarshal([]byte(request.Body), &body); err != nil {
+		return errorResponse(headers, 400, fmt.Sprintf("Invalid request body: %v", err))
+	}
+
+	query, err := buildQuery(body.ReportType, body.Params)
+	if err != nil {
+		return errorResponse(headers, 400, err.Error())
+	}
+
+	rows, columns, err := runAthenaQuery(query)
+	if err != nil {
+		log.Printf("ERROR: Athena query for report %s failed: %v", body.ReportType, err)
+		return errorResponse(headers, 500, fmt.Sprintf("Query failed: %v", err))
+	}
+
+	response := QueryResponse{
+		ReportType: body.ReportType,
+		Columns:    columns,
+		Rows:       rows,
+		Timestamp:  time.Now().UTC().Format(time.RFC3339),
+	}
+	responseBody, _ := json.Marshal(response)
+	return events.APIGatewayProxyResponse{StatusCode: 200, Headers: headers, Body: string(responseBody)}, nil
+}
+
+// buildQuery maps a ReportType to a fixed SQL template, validating any
+// caller-supplied parameters before they're substituted in so this stays a
+// fixed menu of reports rather than a raw-SQL passthrough.
+func buildQuery(reportType string, params map[string]string) (string, error) {
+	switch reportType {
+	case "failures_by_reason":
+		days, err := paramDays(params, 30)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf(`
+			SELECT error_message, COUNT(*) AS failure_count
+			FROM %s
+			WHERE processing_status = 'failed'
+			  AND date_parse(upload_timestamp, '%%Y-%%m-%%dT%%H:%%i:%%sZ') >= date_add('day', -%d, now())
+			GROUP BY error_message
+			ORDER BY failure_count DESC
+		`, analyticsTable, days), nil
+
+	case "average_processing_latency":
+		days, err := paramDays(params, 30)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf(`
+			SELECT
+			  year, month, day,
+			  AVG(date_diff('second',
+			      date_parse(upload_timestamp, '%%Y-%%m-%%dT%%H:%%i:%%sZ'),
+			      date_parse(processing_completed, '%%Y-%%m-%%dT%%H:%%i:%%sZ'))) AS avg_latency_seconds
+			FROM %s
+			WHERE processing_status = 'processed'
+			  AND date_parse(upload_timestamp, '%%Y-%%m-%%dT%%H:%%i:%%sZ') >= date_add('day', -%d, now())
+			GROUP BY year, month, day
+			ORDER BY year, month, day
+		`, analyticsTable, days), nil
+
+	case "throughput_per_day":
+		days, err := paramDays(params, 30)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf(`
+			SELECT year, month, day, COUNT(*) AS files_uploaded
+			FROM %s
+			WHERE date_parse(upload_timestamp, '%%Y-%%m-%%dT%%H:%%i:%%sZ') >= date_add('day', -%d, now())
+			GROUP BY year, month, day
+			ORDER BY year, month, day
+		`, analyticsTable, days), nil
+
+	default:
+		return "", fmt.Errorf("unknown reportType %q", reportType)
+	}
+}
+
+// paramDays parses the "days" lookback window, defaulting and clamping it
+// so an unbounded value can't be used to force a full-table Athena scan.
+func paramDays(params map[string]string, defaultDays int) (int, error) {
+	raw, ok := params["days"]
+	if !ok || raw == "" {
+		return defaultDays, nil
+	}
+	days, err := strconv.Atoi(raw)
+	if err != nil || days < 1 {
+		return 0, fmt.Errorf("params.days must be a positive integer")
+	}
+	if days > 365 {
+		days = 365
+	}
+	return days, nil
+}
+
+// runAthenaQuery submits query, polls until it finishes, and returns the
+// result set as generic string rows plus the column order.
+func runAthenaQuery(query string) ([]map[string]string, []string, error) {
+	startResult, err := athenaClient.StartQueryExecution(&athena.StartQueryExecutionInput{
+		QueryString: aws.String(query),
+		QueryExecutionContext: &athena.QueryExecutionContext{
+			Database: aws.String(analyticsDatabase),
+		},
+		ResultConfiguration: &athena.ResultConfiguration{
+			OutputLocation: aws.String(fmt.Sprintf("s3://%s/athena-results/", analyticsBucket)),
+		},
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to start query: %v", err)
+	}
+
+	queryExecutionID := aws.StringValue(startResult.QueryExecutionId)
+	if err := waitForAthenaQuery(queryExecutionID); err != nil {
+		return nil, nil, err
+	}
+
+	resultsResult, err := athenaClient.GetQueryResults(&athena.GetQueryResultsInput{QueryExecutionId: aws.String(queryExecutionID)})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch query results: %v", err)
+	}
+
+	return parseAthenaResultSet(resultsResult.ResultSet)
+}
+
+func waitForAthenaQuery(queryExecutionID string) error {
+	for i := 0; i < athenaMaxPolls; i++ {
+		result, err := athenaClient.GetQueryExecution(&athena.GetQueryExecutionInput{QueryExecutionId: aws.String(queryExecutionID)})
+		if err != nil {
+			return err
+		}
+
+		state := aws.StringValue(result.QueryExecution.Status.State)
+		switch state {
+		case athena.QueryExecutionStateSucceeded:
+			return nil
+		case athena.QueryExecutionStateFailed, athena.QueryExecutionStateCancelled:
+			return fmt.Errorf("query %s ended in state %s: %s", queryExecutionID, state, aws.StringValue(result.QueryExecution.Status.StateChangeReason))
+		}
+
+		time.Sleep(athenaPollInterval)
+	}
+
+	return fmt.Errorf("query %s did not finish within the polling budget", queryExecutionID)
+}
+
+// parseAthenaResultSet converts Athena's column/row wire format into
+// column-name-keyed maps, skipping the header row Athena includes as the
+// first data row.
+func parseAthenaResultSet(resultSet *athena.ResultSet) ([]map[string]string, []string, error) {
+	if resultSet == nil || len(resultSet.Rows) == 0 {
+		return nil, nil, nil
+	}
+
+	var columns []string
+	for _, col := range resultSet.ResultSetMetadata.ColumnInfo {
+		columns = append(columns, aws.StringValue(col.Name))
+	}
+
+	var rows []map[string]string
+	for i, row := range resultSet.Rows {
+		if i == 0 {
+			continue // header row
+		}
+		record := make(map[string]string, len(columns))
+		for j, datum := range row.Data {
+			if j >= len(columns) {
+				break
+			}
+			record[columns[j]] = aws.StringValue(datum.VarCharValue)
+		}
+		rows = append(rows, record)
+	}
+
+	return rows, columns, nil
+}
+
+func errorResponse(headers map[string]string, statusCode int, message string) (events.APIGatewayProxyResponse, error) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"error":     message,
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+	})
+	return events.APIGatewayProxyResponse{StatusCode: statusCode, Headers: headers, Body: string(body)}, nil
+}
@@ -0,0 +1,554 @@
+package main
+
+// comprehend_chunking.go handles text too long for Comprehend's synchronous
+// APIs (5000 UTF-8 byte cap). Text under COMPREHEND_ASYNC_THRESHOLD_BYTES is
+// split into sentence-aligned windows and analyzed with the Detect* APIs
+// across a bounded worker pool, then merged back into a single result.
+// Larger text is handed off to the async Start*DetectionJob APIs, with the
+// resulting JobIds persisted to DynamoDB for a follow-up Lambda to hydrate
+// once those jobs complete.
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/comprehend"
+	comprehendTypes "github.com/aws/aws-sdk-go-v2/service/comprehend/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodbTypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"golang.org/x/sync/errgroup"
+)
+
+const comprehendChunkMaxBytes = 4900
+const defaultComprehendAsyncThresholdBytes = 100000
+
+// sentenceBoundaryRegex splits text at the same sentence-ending punctuation
+// formatExtractedText uses for its SentenceCount stat, so chunk boundaries
+// line up with what the rest of the pipeline considers a "sentence".
+var sentenceBoundaryRegex = regexp.MustCompile(`[.!?]+\s+`)
+
+// textChunk is a sentence-aligned window of the original text, tagged with
+// its byte offset into that text so per-chunk entity/key-phrase/syntax
+// offsets can be rebased back to the original document's coordinates.
+type textChunk struct {
+	Text   string
+	Offset int
+}
+
+// processLongTextWithComprehend analyzes text above Comprehend's 5000-byte
+// synchronous limit: chunked Detect* calls under
+// defaultComprehendAsyncThresholdBytes, or the async Start*DetectionJob APIs
+// above it.
+func processLongTextWithComprehend(ctx context.Context, text string) ComprehendResult {
+	startTime := time.Now()
+	result := ComprehendResult{
+		OriginalTextLength: len(text),
+		AnalyzedTextLength: len(text),
+		Truncated:          false,
+	}
+
+	asyncThreshold := defaultComprehendAsyncThresholdBytes
+	if v := os.Getenv("COMPREHEND_ASYNC_THRESHOLD_BYTES"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			asyncThreshold = parsed
+		}
+	}
+
+	// Language detection drives the LanguageCode used for every downstream
+	// call; a sample from the start of the document is enough.
+	sample := text
+	if len(sample) > comprehendChunkMaxBytes {
+		sample = sample[:comprehendChunkMaxBytes]
+	}
+	langResp, err := comprehendClient.DetectDominantLanguage(ctx, &comprehend.DetectDominantLanguageInput{
+		Text: aws.String(sample),
+	})
+	langCode := "en"
+	if err != nil {
+		logger.Log(WARN, "WARN", "Language detection failed for long text", map[string]interface{}{"error": err.Error()})
+		result.Language = "unknown"
+	} else if len(langResp.Languages) > 0 {
+		result.Language = aws.ToString(langResp.Languages[0].LanguageCode)
+		result.LanguageScore = aws.ToFloat32(langResp.Languages[0].Score)
+		langCode = result.Language
+	}
+
+	if len(text) > asyncThreshold {
+		logger.Log(INFO, "INFO", "Text exceeds async threshold, submitting Comprehend async jobs", map[string]interface{}{
+			"textLength": len(text),
+			"threshold":  asyncThreshold,
+		})
+
+		jobIDs, err := submitAsyncComprehendJobs(ctx, text, langCode)
+		if err != nil {
+			logger.Log(WARN, "WARN", "Failed to submit async Comprehend jobs", map[string]interface{}{"error": err.Error()})
+			result.Error = err.Error()
+		} else {
+			recordComprehendAsyncJobs(ctx, os.Getenv("FILE_ID"), jobIDs)
+		}
+		result.MergeStrategy = "async-pending"
+		populateEntitySummary(&result)
+		result.ProcessingTime = time.Since(startTime).Seconds()
+		return result
+	}
+
+	chunks := chunkTextForComprehend(text, comprehendChunkMaxBytes)
+	result.ChunkCount = len(chunks)
+	result.MergeStrategy = "chunked-detect"
+
+	logger.Log(INFO, "INFO", "Chunking text for Comprehend analysis", map[string]interface{}{
+		"textLength": len(text),
+		"chunkCount": len(chunks),
+	})
+
+	merged, err := analyzeChunksConcurrently(ctx, chunks, langCode)
+	if err != nil {
+		logger.Log(WARN, "WARN", "Chunked Comprehend analysis failed", map[string]interface{}{"error": err.Error()})
+		result.Error = err.Error()
+	} else {
+		result.Entities = dedupeEntities(merged.entities)
+		result.KeyPhrases = merged.keyPhrases
+		result.Syntax = merged.syntax
+		result.Sentiment, result.PerChunkSentiments = mergeSentiments(merged.sentiments)
+	}
+
+	populateEntitySummary(&result)
+	result.ProcessingTime = time.Since(startTime).Seconds()
+	return result
+}
+
+// chunkTextForComprehend splits text at sentence boundaries into windows no
+// larger than maxBytes, preserving each chunk's byte offset into text.
+func chunkTextForComprehend(text string, maxBytes int) []textChunk {
+	boundaries := sentenceBoundaryRegex.FindAllStringIndex(text, -1)
+
+	var sentences []string
+	last := 0
+	for _, idx := range boundaries {
+		sentences = append(sentences, text[last:idx[1]])
+		last = idx[1]
+	}
+	if last < len(text) {
+		sentences = append(sentences, text[last:])
+	}
+
+	var chunks []textChunk
+	var builder strings.Builder
+	chunkStart := 0
+
+	flush := func() {
+		if builder.Len() > 0 {
+			chunks = append(chunks, textChunk{Text: builder.String(), Offset: chunkStart})
+			chunkStart += builder.Len()
+			builder.Reset()
+		}
+	}
+
+	for _, sentence := range sentences {
+		// A sentence longer than maxBytes on its own is cut at the last
+		// UTF-8 rune boundary that fits, so no chunk exceeds maxBytes.
+		for len(sentence) > maxBytes {
+			flush()
+			cut := maxBytes
+			for cut > 0 && !utf8.RuneStart(sentence[cut]) {
+				cut--
+			}
+			builder.WriteString(sentence[:cut])
+			flush()
+			sentence = sentence[cut:]
+		}
+		if builder.Len() > 0 && builder.Len()+len(sentence) > maxBytes {
+			flush()
+		}
+		builder.WriteString(sentence)
+	}
+	flush()
+
+	return chunks
+}
+
+type chunkAnalysisResult struct {
+	entities   []EntityResult
+	keyPhrases []KeyPhraseResult
+	syntax     []SyntaxResult
+	sentiments []chunkSentiment
+}
+
+type chunkSentiment struct {
+	label  string
+	score  comprehendTypes.SentimentScore
+	weight int
+}
+
+// analyzeChunksConcurrently runs DetectSentiment/DetectEntities/DetectKeyPhrases/
+// DetectSyntax for every chunk concurrently, bounded by
+// COMPREHEND_CHUNK_CONCURRENCY, and merges the per-chunk results.
+func analyzeChunksConcurrently(ctx context.Context, chunks []textChunk, langCode string) (*chunkAnalysisResult, error) {
+	concurrency := 4
+	if v := os.Getenv("COMPREHEND_CHUNK_CONCURRENCY"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			concurrency = parsed
+		}
+	}
+
+	var mu sync.Mutex
+	merged := &chunkAnalysisResult{}
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(concurrency)
+
+	for _, c := range chunks {
+		c := c
+		group.Go(func() error {
+			entities, keyPhrases, syntax, sentiment, err := analyzeChunk(groupCtx, c, langCode)
+			if err != nil {
+				return err
+			}
+
+			mu.Lock()
+			merged.entities = append(merged.entities, entities...)
+			merged.keyPhrases = append(merged.keyPhrases, keyPhrases...)
+			merged.syntax = append(merged.syntax, syntax...)
+			if sentiment != nil {
+				merged.sentiments = append(merged.sentiments, *sentiment)
+			}
+			mu.Unlock()
+			return nil
+		})
+	}
+
+	if err := group.Wait(); err != nil {
+		return merged, err
+	}
+	return merged, nil
+}
+
+// analyzeChunk runs DetectEntities/DetectKeyPhrases/DetectSentiment/DetectSyntax
+// on a single chunk and rebases every offset back to the original document
+// using the chunk's Offset.
+func analyzeChunk(ctx context.Context, chunk textChunk, langCode string) ([]EntityResult, []KeyPhraseResult, []SyntaxResult, *chunkSentiment, error) {
+	var entities []EntityResult
+	var keyPhrases []KeyPhraseResult
+	var syntax []SyntaxResult
+
+	entResp, err := comprehendClient.DetectEntities(ctx, &comprehend.DetectEntitiesInput{
+		Text:         aws.String(chunk.Text),
+		LanguageCode: comprehendTypes.LanguageCode(langCode),
+	})
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("DetectEntities failed: %w", err)
+	}
+	for _, entity := range entResp.Entities {
+		entityType := string(entity.Type)
+		confidence := "Low"
+		if aws.ToFloat32(entity.Score) >= 0.8 {
+			confidence = "High"
+		} else if aws.ToFloat32(entity.Score) >= 0.5 {
+			confidence = "Medium"
+		}
+		entities = append(entities, EntityResult{
+			Text:        aws.ToString(entity.Text),
+			Type:        entityType,
+			Score:       aws.ToFloat32(entity.Score),
+			BeginOffset: aws.ToInt32(entity.BeginOffset) + int32(chunk.Offset),
+			EndOffset:   aws.ToInt32(entity.EndOffset) + int32(chunk.Offset),
+			Length:      aws.ToInt32(entity.EndOffset) - aws.ToInt32(entity.BeginOffset),
+			Category:    getEntityCategory(entityType),
+			Confidence:  confidence,
+		})
+	}
+
+	keyResp, err := comprehendClient.DetectKeyPhrases(ctx, &comprehend.DetectKeyPhrasesInput{
+		Text:         aws.String(chunk.Text),
+		LanguageCode: comprehendTypes.LanguageCode(langCode),
+	})
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("DetectKeyPhrases failed: %w", err)
+	}
+	for _, phrase := range keyResp.KeyPhrases {
+		keyPhrases = append(keyPhrases, KeyPhraseResult{
+			Text:        aws.ToString(phrase.Text),
+			Score:       aws.ToFloat32(phrase.Score),
+			BeginOffset: aws.ToInt32(phrase.BeginOffset) + int32(chunk.Offset),
+			EndOffset:   aws.ToInt32(phrase.EndOffset) + int32(chunk.Offset),
+		})
+	}
+
+	synResp, err := comprehendClient.DetectSyntax(ctx, &comprehend.DetectSyntaxInput{
+		Text:         aws.String(chunk.Text),
+		LanguageCode: comprehendTypes.SyntaxLanguageCode(langCode),
+	})
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("DetectSyntax failed: %w", err)
+	}
+	for _, token := range synResp.SyntaxTokens {
+		syntax = append(syntax, SyntaxResult{
+			Text:         aws.ToString(token.Text),
+			PartOfSpeech: string(token.PartOfSpeech.Tag),
+			Score:        aws.ToFloat32(token.PartOfSpeech.Score),
+			BeginOffset:  aws.ToInt32(token.BeginOffset) + int32(chunk.Offset),
+			EndOffset:    aws.ToInt32(token.EndOffset) + int32(chunk.Offset),
+		})
+	}
+
+	sentResp, err := comprehendClient.DetectSentiment(ctx, &comprehend.DetectSentimentInput{
+		Text:         aws.String(chunk.Text),
+		LanguageCode: comprehendTypes.LanguageCode(langCode),
+	})
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("DetectSentiment failed: %w", err)
+	}
+	var sentiment *chunkSentiment
+	if sentResp.SentimentScore != nil {
+		sentiment = &chunkSentiment{
+			label:  string(sentResp.Sentiment),
+			score:  *sentResp.SentimentScore,
+			weight: len(chunk.Text),
+		}
+	}
+
+	return entities, keyPhrases, syntax, sentiment, nil
+}
+
+// dedupeEntities collapses entities sharing the same (Text, Type) pair,
+// keeping whichever occurrence scored highest.
+func dedupeEntities(entities []EntityResult) []EntityResult {
+	best := make(map[string]EntityResult, len(entities))
+	var order []string
+	for _, entity := range entities {
+		key := entity.Text + "\x00" + entity.Type
+		if existing, ok := best[key]; !ok || entity.Score > existing.Score {
+			if !ok {
+				order = append(order, key)
+			}
+			best[key] = entity
+		}
+	}
+
+	deduped := make([]EntityResult, 0, len(order))
+	for _, key := range order {
+		deduped = append(deduped, best[key])
+	}
+	return deduped
+}
+
+// mergeSentiments returns the per-chunk sentiments alongside an overall
+// SentimentResult whose score is the chunk-length-weighted average and
+// whose Sentiment label is whichever label a plurality of chunks agreed on
+// (ties broken by weighted score).
+func mergeSentiments(sentiments []chunkSentiment) (*SentimentResult, []PerChunkSentiment) {
+	if len(sentiments) == 0 {
+		return nil, nil
+	}
+
+	perChunk := make([]PerChunkSentiment, len(sentiments))
+	votes := make(map[string]int, 4)
+	var totalWeight int
+	var positive, negative, neutral, mixed float64
+
+	for i, s := range sentiments {
+		perChunk[i] = PerChunkSentiment{Sentiment: s.label, SentimentScore: s.score}
+		votes[s.label]++
+
+		weight := float64(s.weight)
+		positive += float64(aws.ToFloat32(s.score.Positive)) * weight
+		negative += float64(aws.ToFloat32(s.score.Negative)) * weight
+		neutral += float64(aws.ToFloat32(s.score.Neutral)) * weight
+		mixed += float64(aws.ToFloat32(s.score.Mixed)) * weight
+		totalWeight += s.weight
+	}
+	if totalWeight == 0 {
+		return nil, perChunk
+	}
+
+	avgScore := comprehendTypes.SentimentScore{
+		Positive: aws.Float32(float32(positive / float64(totalWeight))),
+		Negative: aws.Float32(float32(negative / float64(totalWeight))),
+		Neutral:  aws.Float32(float32(neutral / float64(totalWeight))),
+		Mixed:    aws.Float32(float32(mixed / float64(totalWeight))),
+	}
+
+	majority := "NEUTRAL"
+	bestVotes := -1
+	var bestWeighted float32
+	weightedOf := map[string]float32{
+		"POSITIVE": aws.ToFloat32(avgScore.Positive),
+		"NEGATIVE": aws.ToFloat32(avgScore.Negative),
+		"NEUTRAL":  aws.ToFloat32(avgScore.Neutral),
+		"MIXED":    aws.ToFloat32(avgScore.Mixed),
+	}
+	for label, count := range votes {
+		if count > bestVotes || (count == bestVotes && weightedOf[label] > bestWeighted) {
+			majority, bestVotes, bestWeighted = label, count, weightedOf[label]
+		}
+	}
+
+	return &SentimentResult{
+		Sentiment:      majority,
+		SentimentScore: avgScore,
+	}, perChunk
+}
+
+// populateEntitySummary rebuilds EntitySummary/EntityStats from result.Entities,
+// mirroring the bookkeeping processTextWithComprehend does inline for the
+// single-call path.
+func populateEntitySummary(result *ComprehendResult) {
+	result.EntitySummary = make(map[string][]EntitySummaryItem)
+	uniqueTypes := make(map[string]bool)
+	categories := make(map[string]bool)
+	highConfidence := 0
+
+	for _, entity := range result.Entities {
+		uniqueTypes[entity.Type] = true
+		categories[entity.Category] = true
+		if entity.Confidence == "High" {
+			highConfidence++
+		}
+		result.EntitySummary[entity.Type] = append(result.EntitySummary[entity.Type], EntitySummaryItem{
+			Text:       entity.Text,
+			Score:      entity.Score,
+			Confidence: entity.Confidence,
+		})
+	}
+
+	var uniqueTypesList []string
+	for t := range uniqueTypes {
+		uniqueTypesList = append(uniqueTypesList, t)
+	}
+	var categoriesList []string
+	for c := range categories {
+		categoriesList = append(categoriesList, c)
+	}
+	sort.Strings(uniqueTypesList)
+	sort.Strings(categoriesList)
+
+	result.EntityStats = EntityStats{
+		TotalEntities:          len(result.Entities),
+		UniqueTypes:            uniqueTypesList,
+		HighConfidenceEntities: highConfidence,
+		Categories:             categoriesList,
+	}
+	if result.KeyPhrases == nil {
+		result.KeyPhrases = []KeyPhraseResult{}
+	}
+	if result.Entities == nil {
+		result.Entities = []EntityResult{}
+	}
+	if result.Syntax == nil {
+		result.Syntax = []SyntaxResult{}
+	}
+}
+
+// submitAsyncComprehendJobs uploads text to S3 and starts
+// StartEntitiesDetectionJob/StartKeyPhrasesDetectionJob/StartSentimentDetectionJob
+// for documents too large even for chunked Detect* calls, tagging each job
+// with FILE_ID. It does not wait for completion - the returned JobIds are
+// persisted to DynamoDB so a follow-up Lambda can hydrate ComprehendResult
+// once the jobs finish.
+func submitAsyncComprehendJobs(ctx context.Context, text, langCode string) (map[string]string, error) {
+	bucket := os.Getenv("COMPREHEND_ASYNC_S3_BUCKET")
+	roleArn := os.Getenv("COMPREHEND_ASYNC_ROLE_ARN")
+	if bucket == "" || roleArn == "" {
+		return nil, fmt.Errorf("COMPREHEND_ASYNC_S3_BUCKET/COMPREHEND_ASYNC_ROLE_ARN not configured")
+	}
+
+	fileID := os.Getenv("FILE_ID")
+	inputKey := fmt.Sprintf("comprehend-async/input/%s.txt", fileID)
+	outputPrefix := fmt.Sprintf("comprehend-async/output/%s/", fileID)
+
+	if _, err := s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(inputKey),
+		Body:   strings.NewReader(text),
+	}); err != nil {
+		return nil, fmt.Errorf("failed to upload text for async Comprehend jobs: %w", err)
+	}
+
+	inputConfig := &comprehendTypes.InputDataConfig{
+		S3Uri:       aws.String(fmt.Sprintf("s3://%s/%s", bucket, inputKey)),
+		InputFormat: comprehendTypes.InputFormatOneDocPerFile,
+	}
+	outputConfig := &comprehendTypes.OutputDataConfig{
+		S3Uri: aws.String(fmt.Sprintf("s3://%s/%s", bucket, outputPrefix)),
+	}
+	tags := []comprehendTypes.Tag{{Key: aws.String("fileId"), Value: aws.String(fileID)}}
+
+	entitiesJob, err := comprehendClient.StartEntitiesDetectionJob(ctx, &comprehend.StartEntitiesDetectionJobInput{
+		InputDataConfig:   inputConfig,
+		OutputDataConfig:  outputConfig,
+		DataAccessRoleArn: aws.String(roleArn),
+		LanguageCode:      comprehendTypes.LanguageCode(langCode),
+		JobName:           aws.String(fmt.Sprintf("ocr-entities-%s", fileID)),
+		Tags:              tags,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start async entities job: %w", err)
+	}
+
+	keyPhrasesJob, err := comprehendClient.StartKeyPhrasesDetectionJob(ctx, &comprehend.StartKeyPhrasesDetectionJobInput{
+		InputDataConfig:   inputConfig,
+		OutputDataConfig:  outputConfig,
+		DataAccessRoleArn: aws.String(roleArn),
+		LanguageCode:      comprehendTypes.LanguageCode(langCode),
+		JobName:           aws.String(fmt.Sprintf("ocr-keyphrases-%s", fileID)),
+		Tags:              tags,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start async key phrases job: %w", err)
+	}
+
+	sentimentJob, err := comprehendClient.StartSentimentDetectionJob(ctx, &comprehend.StartSentimentDetectionJobInput{
+		InputDataConfig:   inputConfig,
+		OutputDataConfig:  outputConfig,
+		DataAccessRoleArn: aws.String(roleArn),
+		LanguageCode:      comprehendTypes.LanguageCode(langCode),
+		JobName:           aws.String(fmt.Sprintf("ocr-sentiment-%s", fileID)),
+		Tags:              tags,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start async sentiment job: %w", err)
+	}
+
+	return map[string]string{
+		"entities":   aws.ToString(entitiesJob.JobId),
+		"keyPhrases": aws.ToString(keyPhrasesJob.JobId),
+		"sentiment":  aws.ToString(sentimentJob.JobId),
+	}, nil
+}
+
+// recordComprehendAsyncJobs persists the JobIds from submitAsyncComprehendJobs
+// onto the results row's comprehend_async_jobs map, the same upsert-by-UpdateItem
+// pattern recordStageCheckpoint/updateProcessingProgress use.
+func recordComprehendAsyncJobs(ctx context.Context, fileID string, jobIDs map[string]string) {
+	resultsTable := strings.Replace(os.Getenv("DYNAMODB_TABLE"), "-file-metadata", "-processing-results", 1)
+
+	jobs := make(map[string]dynamodbTypes.AttributeValue, len(jobIDs))
+	for name, jobID := range jobIDs {
+		jobs[name] = &dynamodbTypes.AttributeValueMemberS{Value: jobID}
+	}
+
+	_, err := dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(resultsTable),
+		Key: map[string]dynamodbTypes.AttributeValue{
+			"file_id": &dynamodbTypes.AttributeValueMemberS{Value: fileID},
+		},
+		UpdateExpression: aws.String("SET comprehend_async_jobs = :jobs, comprehend_async_submitted_at = :now"),
+		ExpressionAttributeValues: map[string]dynamodbTypes.AttributeValue{
+			":jobs": &dynamodbTypes.AttributeValueMemberM{Value: jobs},
+			":now":  &dynamodbTypes.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339)},
+		},
+	})
+	if err != nil {
+		logger.Log(WARN, "WARN", "Failed to record async Comprehend job IDs", map[string]interface{}{"fileId": fileID, "error": err.Error()})
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+// deadline.go makes processS3File deadline-aware: AWS Batch retries a job
+// whose container exits non-zero, which is wasted work if it was already
+// doomed to run past its attempt duration. Carving the remaining time into
+// per-stage sub-deadlines lets a stuck Textract/Comprehend call fail fast,
+// persist whatever text was already extracted as a "partial" result, and
+// exit 0 so Batch leaves it alone.
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+)
+
+// jobDeadlineDuration resolves how long this container has before AWS Batch
+// would consider the job attempt over. JOB_DEADLINE_SECONDS takes priority
+// over the AWS-provided AWS_BATCH_JOB_ATTEMPT_DURATION (also seconds);
+// neither being set means no deadline is enforced.
+func jobDeadlineDuration() (time.Duration, bool) {
+	if v := os.Getenv("JOB_DEADLINE_SECONDS"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			return time.Duration(parsed) * time.Second, true
+		}
+	}
+	if v := os.Getenv("AWS_BATCH_JOB_ATTEMPT_DURATION"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			return time.Duration(parsed) * time.Second, true
+		}
+	}
+	return 0, false
+}
+
+// stageDeadline carves out a fraction of ctx's remaining time for a single
+// processing stage, so a slow stage fails on its own sub-deadline instead of
+// silently eating the whole job's budget. If ctx has no deadline, the stage
+// just gets a cancelable child of ctx.
+func stageDeadline(ctx context.Context, fraction float64) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+
+	stageBudget := time.Duration(float64(time.Until(deadline)) * fraction)
+	return context.WithDeadline(ctx, time.Now().Add(stageBudget))
+}
+
+// persistPartialResult records a "partial" status with whatever text had
+// already been extracted when a stage's sub-deadline expired, using a fresh
+// context since ctx itself may already be past its own deadline. It returns
+// a result map with "partial": true and no error so runBatchJob treats this
+// as a clean, non-retriable exit.
+func persistPartialResult(fileID, dynamoTable, partialText, reason string) (map[string]interface{}, error) {
+	logger.Log(WARN, "WARN", "Stage deadline exceeded, persisting partial result", map[string]interface{}{
+		"fileId": fileID,
+		"reason": reason,
+	})
+
+	writeCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := updateFileStatus(writeCtx, dynamoTable, fileID, "partial", map[string]interface{}{
+		"partial_reason": reason,
+		"partial_text":   partialText,
+		"partial_at":     time.Now().UTC().Format(time.RFC3339),
+	}); err != nil {
+		logger.Log(ERROR, "ERROR", "Failed to persist partial status", map[string]interface{}{"fileId": fileID, "error": err.Error()})
+	}
+
+	return map[string]interface{}{
+		"processing_duration": "0.00 seconds",
+		"analysis": map[string]interface{}{
+			"word_count": 0,
+		},
+		"partial": true,
+	}, nil
+}
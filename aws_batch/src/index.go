@@ -1,1092 +1,1420 @@
-package main
-
-import (
-	"context"
-	"encoding/json"
-	"fmt"
-	"log"
-	"os"
-	"os/signal"
-	"regexp"
-	"strings"
-	"sync"
-	"syscall"
-	"time"
-
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/comprehend"
-	comprehendTypes "github.com/aws/aws-sdk-go-v2/service/comprehend/types"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
-	dynamodbTypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
-	"github.com/aws/aws-sdk-go-v2/service/textract"
-	textractTypes "github.com/aws/aws-sdk-go-v2/service/textract/types"
-)
-
-// Logger configuration
-type LogLevel int
-
-const (
-	ERROR LogLevel = iota
-	WARN
-	INFO
-	DEBUG
-)
-
-type Logger struct {
-	level LogLevel
-	mu    sync.Mutex
-}
-
-type LogEntry struct {
-	Timestamp  string                 `json:"timestamp"`
-	Level      string                 `json:"level"`
-	Message    string                 `json:"message"`
-	BatchJobID string                 `json:"batchJobId,omitempty"`
-	FileID     string                 `json:"fileId,omitempty"`
-	Data       map[string]interface{} `json:"data,omitempty"`
-}
-
-var logger *Logger
-
-func NewLogger() *Logger {
-	levelStr := os.Getenv("LOG_LEVEL")
-	if levelStr == "" {
-		levelStr = "INFO"
-	}
-
-	level := INFO
-	switch strings.ToUpper(levelStr) {
-	case "ERROR":
-		level = ERROR
-	case "WARN":
-		level = WARN
-	case "DEBUG":
-		level = DEBUG
-	}
-
-	return &Logger{level: level}
-}
-
-func (l *Logger) Log(level LogLevel, levelStr, message string, data map[string]interface{}) {
-	if level > l.level {
-		return
-	}
-
-	l.mu.Lock()
-	defer l.mu.Unlock()
-
-	entry := LogEntry{
-		Timestamp:  time.Now().UTC().Format(time.RFC3339),
-		Level:      levelStr,
-		Message:    message,
-		BatchJobID: os.Getenv("AWS_BATCH_JOB_ID"),
-		FileID:     os.Getenv("FILE_ID"),
-		Data:       data,
-	}
-
-	jsonEntry, _ := json.Marshal(entry)
-	fmt.Println(string(jsonEntry))
-}
-
-// Processing structures
-type TextractResult struct {
-	Text       string
-	WordCount  int
-	LineCount  int
-	Confidence float32
-	JobID      string
-}
-
-type FormattedText struct {
-	Formatted  string
-	Paragraphs []Paragraph
-	Stats      TextStats
-}
-
-type Paragraph struct {
-	Text      string `json:"text"`
-	Type      string `json:"type"`
-	WordCount int    `json:"wordCount"`
-	CharCount int    `json:"charCount"`
-}
-
-type TextStats struct {
-	ParagraphCount   int `json:"paragraphCount"`
-	SentenceCount    int `json:"sentenceCount"`
-	CleanedChars     int `json:"cleanedChars"`
-	OriginalChars    int `json:"originalChars"`
-	ReductionPercent int `json:"reductionPercent"`
-}
-
-type ComprehendResult struct {
-	Language             string                          `json:"language"`
-	LanguageScore        float32                         `json:"languageScore"`
-	Sentiment            *SentimentResult                `json:"sentiment,omitempty"`
-	Entities             []EntityResult                  `json:"entities"`
-	EntitySummary        map[string][]EntitySummaryItem  `json:"entitySummary"`
-	EntityStats          EntityStats                     `json:"entityStats"`
-	KeyPhrases           []KeyPhraseResult               `json:"keyPhrases"`
-	Syntax               []SyntaxResult                  `json:"syntax"`
-	ProcessingTime       float64                         `json:"processingTime"`
-	AnalyzedTextLength   int                             `json:"analyzedTextLength"`
-	OriginalTextLength   int                             `json:"originalTextLength"`
-	Truncated            bool                            `json:"truncated"`
-	Error                string                          `json:"error,omitempty"`
-}
-
-type SentimentResult struct {
-	Sentiment      string                                   `json:"Sentiment"`
-	SentimentScore comprehendTypes.SentimentScore          `json:"SentimentScore"`
-}
-
-type EntityResult struct {
-	Text         string  `json:"Text"`
-	Type         string  `json:"Type"`
-	Score        float32 `json:"Score"`
-	BeginOffset  int32   `json:"BeginOffset"`
-	EndOffset    int32   `json:"EndOffset"`
-	Length       int32   `json:"Length"`
-	Category     string  `json:"Category"`
-	Confidence   string  `json:"Confidence"`
-}
-
-type EntitySummaryItem struct {
-	Text       string  `json:"text"`
-	Score      float32 `json:"score"`
-	Confidence string  `json:"confidence"`
-}
-
-type EntityStats struct {
-	TotalEntities          int      `json:"totalEntities"`
-	UniqueTypes            []string `json:"uniqueTypes"`
-	HighConfidenceEntities int      `json:"highConfidenceEntities"`
-	Categories             []string `json:"categories"`
-}
-
-type KeyPhraseResult struct {
-	Text        string  `json:"Text"`
-	Score       float32 `json:"Score"`
-	BeginOffset int32   `json:"BeginOffset"`
-	EndOffset   int32   `json:"EndOffset"`
-}
-
-type SyntaxResult struct {
-	Text         string  `json:"Text"`
-	PartOfSpeech string  `json:"PartOfSpeech"`
-	Score        float32 `json:"Score"`
-	BeginOffset  int32   `json:"BeginOffset"`
-	EndOffset    int32   `json:"EndOffset"`
-}
-
-// AWS clients
-var (
-	s3Client         *s3.Client
-	dynamoClient     *dynamodb.Client
-	textractClient   *textract.Client
-	comprehendClient *comprehend.Client
-)
-
-func init() {
-	logger = NewLogger()
-}
-
-func main() {
-	// Set up signal handling
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT)
-
-	go func() {
-		sig := <-sigChan
-		logger.Log(INFO, "INFO", fmt.Sprintf("Received signal: %v, shutting down gracefully", sig), nil)
-		os.Exit(0)
-	}()
-
-	// Log startup info
-	isDev := os.Getenv("NODE_ENV") == "development"
-	if isDev || logger.level == DEBUG {
-		logger.Log(DEBUG, "DEBUG", "Container startup debug info", map[string]interface{}{
-			"goVersion": strings.TrimPrefix(strings.TrimSpace(strings.Split(os.Args[0], " ")[0]), "go"),
-			"environment": map[string]string{
-				"AWS_BATCH_JOB_ID": os.Getenv("AWS_BATCH_JOB_ID"),
-				"S3_BUCKET":        os.Getenv("S3_BUCKET"),
-				"S3_KEY":           os.Getenv("S3_KEY"),
-				"FILE_ID":          os.Getenv("FILE_ID"),
-				"DYNAMODB_TABLE":   os.Getenv("DYNAMODB_TABLE"),
-				"AWS_REGION":       os.Getenv("AWS_REGION"),
-			},
-		})
-	} else {
-		hasRequiredEnvVars := os.Getenv("S3_BUCKET") != "" && os.Getenv("S3_KEY") != "" &&
-			os.Getenv("FILE_ID") != "" && os.Getenv("DYNAMODB_TABLE") != ""
-		logger.Log(INFO, "INFO", "OCR Processor starting - batch mode only", map[string]interface{}{
-			"hasRequiredEnvVars": hasRequiredEnvVars,
-		})
-	}
-
-	// Initialize AWS clients
-	ctx := context.Background()
-	if err := initializeAWSClients(ctx); err != nil {
-		logger.Log(ERROR, "ERROR", "Failed to initialize AWS clients", map[string]interface{}{
-			"error": err.Error(),
-		})
-		os.Exit(1)
-	}
-
-	// Run batch job with small delay for logging setup
-	time.Sleep(100 * time.Millisecond)
-	if err := runBatchJob(ctx); err != nil {
-		logger.Log(ERROR, "ERROR", "Batch job failed", map[string]interface{}{
-			"error": err.Error(),
-		})
-		os.Exit(1)
-	}
-}
-
-func initializeAWSClients(ctx context.Context) error {
-	cfg, err := config.LoadDefaultConfig(ctx)
-	if err != nil {
-		return fmt.Errorf("unable to load SDK config: %w", err)
-	}
-
-	s3Client = s3.NewFromConfig(cfg)
-	dynamoClient = dynamodb.NewFromConfig(cfg)
-	textractClient = textract.NewFromConfig(cfg)
-	comprehendClient = comprehend.NewFromConfig(cfg)
-
-	return nil
-}
-
-func runBatchJob(ctx context.Context) error {
-	// Validate required environment variables
-	requiredVars := []string{"S3_BUCKET", "S3_KEY", "FILE_ID", "DYNAMODB_TABLE"}
-	var missingVars []string
-	for _, v := range requiredVars {
-		if os.Getenv(v) == "" {
-			missingVars = append(missingVars, v)
-		}
-	}
-
-	if len(missingVars) > 0 {
-		logger.Log(ERROR, "ERROR", "Missing required environment variables", map[string]interface{}{
-			"missingVars": missingVars,
-		})
-		return fmt.Errorf("missing required environment variables: %v", missingVars)
-	}
-
-	logger.Log(INFO, "INFO", "Starting batch processing", map[string]interface{}{
-		"batchJobId": os.Getenv("AWS_BATCH_JOB_ID"),
-		"jobQueue":   os.Getenv("AWS_BATCH_JQ_NAME"),
-	})
-
-	result, err := processS3File(ctx)
-	if err != nil {
-		return err
-	}
-
-	logger.Log(INFO, "INFO", "Batch job completed successfully", map[string]interface{}{
-		"processingDuration": result["processing_duration"],
-		"textExtracted":      result["analysis"].(map[string]interface{})["word_count"].(int) > 0,
-	})
-
-	return nil
-}
-
-func processS3File(ctx context.Context) (map[string]interface{}, error) {
-	bucketName := os.Getenv("S3_BUCKET")
-	objectKey := os.Getenv("S3_KEY")
-	fileID := os.Getenv("FILE_ID")
-	dynamoTable := os.Getenv("DYNAMODB_TABLE")
-
-	logger.Log(INFO, "INFO", "Starting file processing", map[string]interface{}{
-		"bucket": bucketName,
-		"key":    objectKey,
-		"fileId": fileID,
-		"table":  dynamoTable,
-	})
-
-	// Update status to processing
-	if err := updateFileStatus(ctx, dynamoTable, fileID, "processing", map[string]interface{}{
-		"processing_started": time.Now().UTC().Format(time.RFC3339),
-		"batch_job_id":       os.Getenv("AWS_BATCH_JOB_ID"),
-	}); err != nil {
-		return nil, err
-	}
-
-	// Get file metadata
-	headResp, err := s3Client.HeadObject(ctx, &s3.HeadObjectInput{
-		Bucket: aws.String(bucketName),
-		Key:    aws.String(objectKey),
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to get S3 object metadata: %w", err)
-	}
-
-	fileSize := headResp.ContentLength
-	contentType := aws.ToString(headResp.ContentType)
-
-	logger.Log(INFO, "INFO", "File metadata retrieved", map[string]interface{}{
-		"size":         fileSize,
-		"contentType":  contentType,
-		"lastModified": headResp.LastModified,
-	})
-
-	// Process with Textract
-	startTime := time.Now()
-	extractedData, err := processFileWithTextract(ctx, bucketName, objectKey)
-	if err != nil {
-		updateFileStatus(ctx, dynamoTable, fileID, "failed", map[string]interface{}{
-			"error_message": err.Error(),
-			"failed_at":     time.Now().UTC().Format(time.RFC3339),
-		})
-		return nil, err
-	}
-	textractTime := time.Since(startTime).Seconds()
-
-	logger.Log(INFO, "INFO", "Textract processing completed", map[string]interface{}{
-		"processingTimeSeconds": textractTime,
-		"wordCount":             extractedData.WordCount,
-		"lineCount":             extractedData.LineCount,
-		"confidence":            extractedData.Confidence,
-	})
-
-	// Format extracted text
-	var formattedTextData FormattedText
-	textForComprehend := extractedData.Text
-
-	if strings.TrimSpace(extractedData.Text) != "" {
-		logger.Log(INFO, "INFO", "Formatting extracted text", nil)
-		formattedTextData = formatExtractedText(extractedData.Text)
-		if formattedTextData.Formatted != "" {
-			textForComprehend = formattedTextData.Formatted
-		}
-
-		logger.Log(INFO, "INFO", "Text formatting completed", map[string]interface{}{
-			"originalChars": formattedTextData.Stats.OriginalChars,
-			"cleanedChars":  formattedTextData.Stats.CleanedChars,
-			"paragraphs":    formattedTextData.Stats.ParagraphCount,
-			"sentences":     formattedTextData.Stats.SentenceCount,
-			"reduction":     fmt.Sprintf("%d%%", formattedTextData.Stats.ReductionPercent),
-		})
-	}
-
-	// Process with Comprehend
-	var comprehendData ComprehendResult
-	if strings.TrimSpace(textForComprehend) != "" {
-		logger.Log(INFO, "INFO", "Starting Comprehend analysis on formatted text", nil)
-		comprehendStartTime := time.Now()
-		comprehendData = processTextWithComprehend(ctx, textForComprehend)
-		comprehendTime := time.Since(comprehendStartTime).Seconds()
-
-		logger.Log(INFO, "INFO", "Comprehend analysis completed", map[string]interface{}{
-			"processingTimeSeconds": comprehendTime,
-			"language":              comprehendData.Language,
-			"sentiment":             comprehendData.Sentiment,
-			"entitiesCount":         len(comprehendData.Entities),
-			"keyPhrasesCount":       len(comprehendData.KeyPhrases),
-		})
-	} else {
-		logger.Log(INFO, "INFO", "Skipping Comprehend analysis - no text extracted", nil)
-	}
-
-	totalProcessingTime := time.Since(startTime).Seconds()
-
-	// Generate processing results
-	processingResults := map[string]interface{}{
-		"processed_at":     time.Now().UTC().Format(time.RFC3339),
-		"file_size":        fileSize,
-		"content_type":     contentType,
-		"processing_duration": fmt.Sprintf("%.2f seconds", totalProcessingTime),
-		"extracted_text":   extractedData.Text,
-		"formatted_text":   formattedTextData.Formatted,
-		"text_formatting": map[string]interface{}{
-			"paragraphs":     formattedTextData.Paragraphs,
-			"stats":          formattedTextData.Stats,
-			"hasFormatting":  formattedTextData.Formatted != "",
-		},
-		"analysis": map[string]interface{}{
-			"word_count":      extractedData.WordCount,
-			"character_count": len(extractedData.Text),
-			"line_count":      extractedData.LineCount,
-			"confidence":      extractedData.Confidence,
-		},
-		"comprehend_analysis": comprehendData,
-		"metadata": map[string]interface{}{
-			"processor_version": "2.2.0",
-			"batch_job_id":      os.Getenv("AWS_BATCH_JOB_ID"),
-			"textract_job_id":   extractedData.JobID,
-			"textract_duration": fmt.Sprintf("%.2f seconds", textractTime),
-			"comprehend_duration": fmt.Sprintf("%.2f seconds", comprehendData.ProcessingTime),
-		},
-	}
-
-	// Store results
-	if err := storeProcessingResults(ctx, fileID, processingResults); err != nil {
-		return nil, err
-	}
-
-	// Update status to processed
-	if err := updateFileStatus(ctx, dynamoTable, fileID, "processed", map[string]interface{}{
-		"processing_completed": time.Now().UTC().Format(time.RFC3339),
-		"processing_duration":  processingResults["processing_duration"],
-	}); err != nil {
-		return nil, err
-	}
-
-	logger.Log(INFO, "INFO", "File processing completed successfully", map[string]interface{}{
-		"processingTimeSeconds": totalProcessingTime,
-		"extractedWords":        extractedData.WordCount,
-		"extractedLines":        extractedData.LineCount,
-		"confidence":            extractedData.Confidence,
-		"comprehendLanguage":    comprehendData.Language,
-		"comprehendSentiment":   comprehendData.Sentiment,
-	})
-
-	return processingResults, nil
-}
-
-func processFileWithTextract(ctx context.Context, bucketName, objectKey string) (*TextractResult, error) {
-	logger.Log(INFO, "INFO", "Starting Textract document analysis", map[string]interface{}{
-		"s3Uri": fmt.Sprintf("s3://%s/%s", bucketName, objectKey),
-	})
-
-	// Start document analysis
-	startResp, err := textractClient.StartDocumentAnalysis(ctx, &textract.StartDocumentAnalysisInput{
-		DocumentLocation: &textractTypes.DocumentLocation{
-			S3Object: &textractTypes.S3Object{
-				Bucket: aws.String(bucketName),
-				Name:   aws.String(objectKey),
-			},
-		},
-		FeatureTypes: []textractTypes.FeatureType{
-			textractTypes.FeatureTypeTables,
-			textractTypes.FeatureTypeForms,
-		},
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to start Textract analysis: %w", err)
-	}
-
-	jobID := aws.ToString(startResp.JobId)
-	logger.Log(INFO, "INFO", "Textract job submitted", map[string]interface{}{
-		"textractJobId": jobID,
-	})
-
-	// Wait for completion
-	maxAttempts := 60
-	for attempts := 0; attempts < maxAttempts; attempts++ {
-		time.Sleep(5 * time.Second)
-
-		statusResp, err := textractClient.GetDocumentAnalysis(ctx, &textract.GetDocumentAnalysisInput{
-			JobId: aws.String(jobID),
-		})
-		if err != nil {
-			return nil, fmt.Errorf("failed to get Textract status: %w", err)
-		}
-
-		jobStatus := statusResp.JobStatus
-		if attempts%6 == 0 {
-			logger.Log(INFO, "INFO", "Waiting for Textract completion", map[string]interface{}{
-				"status":      jobStatus,
-				"attempt":     attempts,
-				"maxAttempts": maxAttempts,
-			})
-		}
-
-		if jobStatus == textractTypes.JobStatusFailed {
-			return nil, fmt.Errorf("Textract job failed: %s", aws.ToString(statusResp.StatusMessage))
-		}
-
-		if jobStatus == textractTypes.JobStatusSucceeded {
-			break
-		}
-
-		if attempts == maxAttempts-1 {
-			return nil, fmt.Errorf("Textract job timeout after %d attempts", maxAttempts)
-		}
-	}
-
-	// Get all results
-	var allBlocks []textractTypes.Block
-	var nextToken *string
-	pageCount := 0
-
-	for {
-		resp, err := textractClient.GetDocumentAnalysis(ctx, &textract.GetDocumentAnalysisInput{
-			JobId:     aws.String(jobID),
-			NextToken: nextToken,
-		})
-		if err != nil {
-			return nil, fmt.Errorf("failed to get Textract results: %w", err)
-		}
-
-		allBlocks = append(allBlocks, resp.Blocks...)
-		nextToken = resp.NextToken
-		pageCount++
-
-		if nextToken == nil {
-			break
-		}
-	}
-
-	logger.Log(DEBUG, "DEBUG", "Textract results retrieved", map[string]interface{}{
-		"totalBlocks": len(allBlocks),
-		"pages":       pageCount,
-	})
-
-	// Extract text
-	var extractedText []string
-	var totalConfidence float32
-	confidenceCount := 0
-
-	for _, block := range allBlocks {
-		if block.BlockType == textractTypes.BlockTypeLine && block.Text != nil {
-			extractedText = append(extractedText, aws.ToString(block.Text))
-			if block.Confidence != nil {
-				totalConfidence += aws.ToFloat32(block.Confidence)
-				confidenceCount++
-			}
-		}
-	}
-
-	fullText := strings.Join(extractedText, "\n")
-	words := strings.Fields(fullText)
-
-	avgConfidence := float32(0)
-	if confidenceCount > 0 {
-		avgConfidence = totalConfidence / float32(confidenceCount)
-	}
-
-	return &TextractResult{
-		Text:       fullText,
-		WordCount:  len(words),
-		LineCount:  len(extractedText),
-		Confidence: avgConfidence,
-		JobID:      jobID,
-	}, nil
-}
-
-func formatExtractedText(rawText string) FormattedText {
-	if rawText == "" {
-		return FormattedText{
-			Formatted:  "",
-			Paragraphs: []Paragraph{},
-			Stats: TextStats{
-				ParagraphCount: 0,
-				SentenceCount:  0,
-				CleanedChars:   0,
-			},
-		}
-	}
-
-	// Fix URLs and emails
-	preprocessed := fixURLsAndEmails(rawText)
-
-	// Continue with other preprocessing
-	preprocessed = regexp.MustCompile(`\.\s+([A-Z])`).ReplaceAllString(preprocessed, ". $1")
-	preprocessed = regexp.MustCompile(`([a-z])\s+([A-Z])`).ReplaceAllString(preprocessed, "$1 $2")
-	preprocessed = regexp.MustCompile(`(\w)\s+([,.])`).ReplaceAllString(preprocessed, "$1$2")
-	preprocessed = regexp.MustCompile(`([,.!?;:])\s*`).ReplaceAllString(preprocessed, "$1 ")
-	preprocessed = regexp.MustCompile(`\n{4,}`).ReplaceAllString(preprocessed, "\n\n\n")
-	preprocessed = strings.ReplaceAll(preprocessed, "\r", "")
-	preprocessed = strings.ReplaceAll(preprocessed, "\t", " ")
-
-	// Smart line joining
-	lines := strings.Split(preprocessed, "\n")
-	var processedLines []string
-	currentLine := ""
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-
-		if line == "" {
-			if currentLine != "" {
-				processedLines = append(processedLines, currentLine)
-				currentLine = ""
-			}
-			processedLines = append(processedLines, "")
-			continue
-		}
-
-		isVeryShort := len(line) < 20
-		endsWithPunctuation := regexp.MustCompile(`[.!?]$`).MatchString(currentLine)
-		startsWithCapital := regexp.MustCompile(`^[A-Z]`).MatchString(line)
-		looksLikeHeading := len(line) < 40 && line == strings.ToUpper(line)
-
-		if currentLine != "" && !endsWithPunctuation && !startsWithCapital && !looksLikeHeading && !isVeryShort {
-			currentLine += " " + line
-		} else {
-			if currentLine != "" {
-				processedLines = append(processedLines, currentLine)
-			}
-			currentLine = line
-		}
-	}
-
-	if currentLine != "" {
-		processedLines = append(processedLines, currentLine)
-	}
-
-	// Create paragraphs
-	var paragraphs []Paragraph
-	var currentParagraph []string
-
-	for _, line := range processedLines {
-		if line == "" {
-			if len(currentParagraph) > 0 {
-				text := strings.Join(currentParagraph, " ")
-				text = strings.TrimSpace(text)
-				if text != "" {
-					paragraphs = append(paragraphs, Paragraph{
-						Text:      text,
-						Type:      "paragraph",
-						WordCount: len(strings.Fields(text)),
-						CharCount: len(text),
-					})
-				}
-				currentParagraph = []string{}
-			}
-		} else {
-			currentParagraph = append(currentParagraph, line)
-		}
-	}
-
-	if len(currentParagraph) > 0 {
-		text := strings.Join(currentParagraph, " ")
-		text = strings.TrimSpace(text)
-		if text != "" {
-			paragraphs = append(paragraphs, Paragraph{
-				Text:      text,
-				Type:      "paragraph",
-				WordCount: len(strings.Fields(text)),
-				CharCount: len(text),
-			})
-		}
-	}
-
-	// Create formatted output
-	var formattedParts []string
-	for _, p := range paragraphs {
-		formattedParts = append(formattedParts, p.Text)
-	}
-	formatted := strings.Join(formattedParts, "\n\n")
-
-	// Final cleanup
-	formatted = fixURLsAndEmails(formatted)
-	formatted = regexp.MustCompile(`\s+([,.!?;:])`).ReplaceAllString(formatted, "$1")
-	formatted = regexp.MustCompile(`([,.!?;:])(?!\s|$)`).ReplaceAllString(formatted, "$1 ")
-	formatted = regexp.MustCompile(` {2,}`).ReplaceAllString(formatted, " ")
-	formatted = strings.TrimSpace(formatted)
-
-	// Calculate stats
-	sentences := regexp.MustCompile(`[.!?]+`).FindAllString(formatted, -1)
-	stats := TextStats{
-		ParagraphCount:   len(paragraphs),
-		SentenceCount:    len(sentences),
-		CleanedChars:     len(formatted),
-		OriginalChars:    len(rawText),
-		ReductionPercent: int(float64(len(rawText)-len(formatted)) / float64(len(rawText)) * 100),
-	}
-
-	return FormattedText{
-		Formatted:  formatted,
-		Paragraphs: paragraphs,
-		Stats:      stats,
-	}
-}
-
-func fixURLsAndEmails(text string) string {
-	// Fix emails
-	emailRegex := regexp.MustCompile(`(\w+)\s*@\s*([^\s\n\r\t]+)`)
-	text = emailRegex.ReplaceAllStringFunc(text, func(match string) string {
-		parts := strings.Split(match, "@")
-		if len(parts) == 2 {
-			user := strings.TrimSpace(parts[0])
-			domain := strings.ReplaceAll(parts[1], " ", "")
-			domain = regexp.MustCompile(`\.\s+`).ReplaceAllString(domain, ".")
-			return user + "@" + domain
-		}
-		return match
-	})
-
-	// Fix URLs starting with www.
-	wwwRegex := regexp.MustCompile(`www\.\s+([^\s\n\r\t]+?)(\s+(?:I|,|\||$))`)
-	text = wwwRegex.ReplaceAllStringFunc(text, func(match string) string {
-		urlPart := regexp.MustCompile(`www\.\s+`).ReplaceAllString(match, "www.")
-		urlPart = regexp.MustCompile(`\.\s+`).ReplaceAllString(urlPart, ".")
-		urlPart = regexp.MustCompile(`\s+\.`).ReplaceAllString(urlPart, ".")
-		return strings.ReplaceAll(urlPart, " ", "")
-	})
-
-	// Fix domain patterns
-	text = regexp.MustCompile(`(\w+)\.\s+(\w+)\.\s+(\w+)(?:\s|$|[^\w])`).ReplaceAllString(text, "$1.$2.$3")
-	text = regexp.MustCompile(`(\w+)\.\s+(\w+)(?:\s|$|[^\w])`).ReplaceAllString(text, "$1.$2")
-
-	// Fix http:// and https://
-	text = regexp.MustCompile(`https?\s*:\s*\/\s*\/\s*`).ReplaceAllStringFunc(text, func(match string) string {
-		return strings.ReplaceAll(match, " ", "")
-	})
-
-	// Fix TLDs
-	tldRegex := regexp.MustCompile(`(\S+)\.\s+(\w{2,3})(?:\s|$|[^\w])`)
-	text = tldRegex.ReplaceAllStringFunc(text, func(match string) string {
-		parts := regexp.MustCompile(`\.\s+`).Split(match, -1)
-		if len(parts) == 2 {
-			domain := parts[0]
-			tld := strings.TrimSpace(parts[1])
-			if regexp.MustCompile(`^(com|net|org|edu|gov|mil|int|nz|au|uk|us|ca|de|fr|jp|cn|io|co|me|info|biz)$`).MatchString(strings.ToLower(tld)) {
-				return domain + "." + tld
-			}
-		}
-		return match
-	})
-
-	return text
-}
-
-func getEntityCategory(entityType string) string {
-	categories := map[string]string{
-		"PERSON":          "People",
-		"LOCATION":        "Places",
-		"ORGANIZATION":    "Organizations",
-		"COMMERCIAL_ITEM": "Products & Services",
-		"EVENT":           "Events",
-		"DATE":            "Dates & Times",
-		"QUANTITY":        "Numbers & Quantities",
-		"TITLE":           "Titles & Positions",
-		"OTHER":           "Other",
-	}
-
-	if category, ok := categories[entityType]; ok {
-		return category
-	}
-	return "Other"
-}
-
-func processTextWithComprehend(ctx context.Context, text string) ComprehendResult {
-	const maxLength = 5000
-	textToAnalyze := text
-	if len(text) > maxLength {
-		textToAnalyze = text[:maxLength]
-	}
-
-	logger.Log(INFO, "INFO", "Starting Comprehend analysis", map[string]interface{}{
-		"originalLength": len(text),
-		"analyzedLength": len(textToAnalyze),
-		"truncated":      len(text) > maxLength,
-	})
-
-	startTime := time.Now()
-	result := ComprehendResult{
-		OriginalTextLength: len(text),
-		AnalyzedTextLength: len(textToAnalyze),
-		Truncated:          len(text) > maxLength,
-	}
-
-	// Language detection
-	langResp, err := comprehendClient.DetectDominantLanguage(ctx, &comprehend.DetectDominantLanguageInput{
-		Text: aws.String(textToAnalyze),
-	})
-	if err != nil {
-		logger.Log(WARN, "WARN", "Language detection failed", map[string]interface{}{
-			"error": err.Error(),
-		})
-		result.Language = "unknown"
-		result.LanguageScore = 0
-	} else if len(langResp.Languages) > 0 {
-		result.Language = aws.ToString(langResp.Languages[0].LanguageCode)
-		result.LanguageScore = aws.ToFloat32(langResp.Languages[0].Score)
-	}
-
-	langCode := result.Language
-	if langCode == "unknown" {
-		langCode = "en"
-	}
-
-	// Sentiment analysis
-	sentResp, err := comprehendClient.DetectSentiment(ctx, &comprehend.DetectSentimentInput{
-		Text:         aws.String(textToAnalyze),
-		LanguageCode: aws.String(langCode),
-	})
-	if err != nil {
-		logger.Log(WARN, "WARN", "Sentiment analysis failed", map[string]interface{}{
-			"error": err.Error(),
-		})
-	} else {
-		result.Sentiment = &SentimentResult{
-			Sentiment:      string(sentResp.Sentiment),
-			SentimentScore: *sentResp.SentimentScore,
-		}
-	}
-
-	// Entity detection
-	entResp, err := comprehendClient.DetectEntities(ctx, &comprehend.DetectEntitiesInput{
-		Text:         aws.String(textToAnalyze),
-		LanguageCode: aws.String(langCode),
-	})
-	if err != nil {
-		logger.Log(WARN, "WARN", "Entity detection failed", map[string]interface{}{
-			"error": err.Error(),
-		})
-		result.Entities = []EntityResult{}
-		result.EntitySummary = make(map[string][]EntitySummaryItem)
-	} else {
-		result.Entities = make([]EntityResult, len(entResp.Entities))
-		result.EntitySummary = make(map[string][]EntitySummaryItem)
-		uniqueTypes := make(map[string]bool)
-		categories := make(map[string]bool)
-		highConfidence := 0
-
-		for i, entity := range entResp.Entities {
-			confidence := "Low"
-			if aws.ToFloat32(entity.Score) >= 0.8 {
-				confidence = "High"
-				highConfidence++
-			} else if aws.ToFloat32(entity.Score) >= 0.5 {
-				confidence = "Medium"
-			}
-
-			entityType := string(entity.Type)
-			category := getEntityCategory(entityType)
-			
-			result.Entities[i] = EntityResult{
-				Text:        aws.ToString(entity.Text),
-				Type:        entityType,
-				Score:       aws.ToFloat32(entity.Score),
-				BeginOffset: aws.ToInt32(entity.BeginOffset),
-				EndOffset:   aws.ToInt32(entity.EndOffset),
-				Length:      aws.ToInt32(entity.EndOffset) - aws.ToInt32(entity.BeginOffset),
-				Category:    category,
-				Confidence:  confidence,
-			}
-
-			uniqueTypes[entityType] = true
-			categories[category] = true
-
-			if _, ok := result.EntitySummary[entityType]; !ok {
-				result.EntitySummary[entityType] = []EntitySummaryItem{}
-			}
-			result.EntitySummary[entityType] = append(result.EntitySummary[entityType], EntitySummaryItem{
-				Text:       aws.ToString(entity.Text),
-				Score:      aws.ToFloat32(entity.Score),
-				Confidence: confidence,
-			})
-		}
-
-		var uniqueTypesList []string
-		for t := range uniqueTypes {
-			uniqueTypesList = append(uniqueTypesList, t)
-		}
-		var categoriesList []string
-		for c := range categories {
-			categoriesList = append(categoriesList, c)
-		}
-
-		result.EntityStats = EntityStats{
-			TotalEntities:          len(result.Entities),
-			UniqueTypes:            uniqueTypesList,
-			HighConfidenceEntities: highConfidence,
-			Categories:             categoriesList,
-		}
-	}
-
-	// Key phrases
-	keyResp, err := comprehendClient.DetectKeyPhrases(ctx, &comprehend.DetectKeyPhrasesInput{
-		Text:         aws.String(textToAnalyze),
-		LanguageCode: aws.String(langCode),
-	})
-	if err != nil {
-		logger.Log(WARN, "WARN", "Key phrases extraction failed", map[string]interface{}{
-			"error": err.Error(),
-		})
-		result.KeyPhrases = []KeyPhraseResult{}
-	} else {
-		result.KeyPhrases = make([]KeyPhraseResult, len(keyResp.KeyPhrases))
-		for i, phrase := range keyResp.KeyPhrases {
-			result.KeyPhrases[i] = KeyPhraseResult{
-				Text:        aws.ToString(phrase.Text),
-				Score:       aws.ToFloat32(phrase.Score),
-				BeginOffset: aws.ToInt32(phrase.BeginOffset),
-				EndOffset:   aws.ToInt32(phrase.EndOffset),
-			}
-		}
-	}
-
-	// Syntax analysis
-	synResp, err := comprehendClient.DetectSyntax(ctx, &comprehend.DetectSyntaxInput{
-		Text:         aws.String(textToAnalyze),
-		LanguageCode: aws.String(langCode),
-	})
-	if err != nil {
-		logger.Log(WARN, "WARN", "Syntax analysis failed", map[string]interface{}{
-			"error": err.Error(),
-		})
-		result.Syntax = []SyntaxResult{}
-	} else {
-		result.Syntax = make([]SyntaxResult, len(synResp.SyntaxTokens))
-		for i, token := range synResp.SyntaxTokens {
-			result.Syntax[i] = SyntaxResult{
-				Text:         aws.ToString(token.Text),
-				PartOfSpeech: string(token.PartOfSpeech.Tag),
-				Score:        aws.ToFloat32(token.PartOfSpeech.Score),
-				BeginOffset:  aws.ToInt32(token.BeginOffset),
-				EndOffset:    aws.ToInt32(token.EndOffset),
-			}
-		}
-	}
-
-	result.ProcessingTime = time.Since(startTime).Seconds()
-	return result
-}
-
-func updateFileStatus(ctx context.Context, tableName, fileID, status string, additionalData map[string]interface{}) error {
-	// First get the upload_timestamp
-	queryResp, err := dynamoClient.Query(ctx, &dynamodb.QueryInput{
-		TableName:              aws.String(tableName),
-		KeyConditionExpression: aws.String("file_id = :fileId"),
-		ExpressionAttributeValues: map[string]dynamodbTypes.AttributeValue{
-			":fileId": &dynamodbTypes.AttributeValueMemberS{Value: fileID},
-		},
-		Limit: aws.Int32(1),
-	})
-	if err != nil {
-		return fmt.Errorf("failed to query file: %w", err)
-	}
-
-	if len(queryResp.Items) == 0 {
-		return fmt.Errorf("file with ID %s not found in database", fileID)
-	}
-
-	uploadTimestamp := queryResp.Items[0]["upload_timestamp"].(*dynamodbTypes.AttributeValueMemberS).Value
-
-	// Update the item
-	updateExpr := "SET processing_status = :status, last_updated = :updated"
-	exprAttrValues := map[string]dynamodbTypes.AttributeValue{
-		":status":  &dynamodbTypes.AttributeValueMemberS{Value: status},
-		":updated": &dynamodbTypes.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339)},
-	}
-
-	// Add additional data
-	for key, value := range additionalData {
-		attrName := fmt.Sprintf(":val%s", key)
-		updateExpr += fmt.Sprintf(", %s = %s", key, attrName)
-		
-		switch v := value.(type) {
-		case string:
-			exprAttrValues[attrName] = &dynamodbTypes.AttributeValueMemberS{Value: v}
-		case int:
-			exprAttrValues[attrName] = &dynamodbTypes.AttributeValueMemberN{Value: fmt.Sprintf("%d", v)}
-		case float64:
-			exprAttrValues[attrName] = &dynamodbTypes.AttributeValueMemberN{Value: fmt.Sprintf("%f", v)}
-		default:
-			jsonBytes, _ := json.Marshal(v)
-			exprAttrValues[attrName] = &dynamodbTypes.AttributeValueMemberS{Value: string(jsonBytes)}
-		}
-	}
-
-	_, err = dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
-		TableName: aws.String(tableName),
-		Key: map[string]dynamodbTypes.AttributeValue{
-			"file_id":          &dynamodbTypes.AttributeValueMemberS{Value: fileID},
-			"upload_timestamp": &dynamodbTypes.AttributeValueMemberS{Value: uploadTimestamp},
-		},
-		UpdateExpression:          aws.String(updateExpr),
-		ExpressionAttributeValues: exprAttrValues,
-	})
-
-	if err != nil {
-		logger.Log(ERROR, "ERROR", "Failed to update file status", map[string]interface{}{
-			"fileId": fileID,
-			"status": status,
-			"error":  err.Error(),
-		})
-		return err
-	}
-
-	logger.Log(DEBUG, "DEBUG", "DynamoDB status updated", map[string]interface{}{
-		"fileId": fileID,
-		"status": status,
-	})
-	return nil
-}
-
-func storeProcessingResults(ctx context.Context, fileID string, results map[string]interface{}) error {
-	resultsTable := strings.Replace(os.Getenv("DYNAMODB_TABLE"), "-file-metadata", "-processing-results", 1)
-
-	item := map[string]dynamodbTypes.AttributeValue{
-		"file_id": &dynamodbTypes.AttributeValueMemberS{Value: fileID},
-	}
-
-	// Convert results to DynamoDB attributes
-	for key, value := range results {
-		attrValue, err := attributeValueFromInterface(value)
-		if err != nil {
-			logger.Log(WARN, "WARN", "Failed to convert attribute", map[string]interface{}{
-				"key":   key,
-				"error": err.Error(),
-			})
-			continue
-		}
-		item[key] = attrValue
-	}
-
-	_, err := dynamoClient.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(resultsTable),
-		Item:      item,
-	})
-
-	if err != nil {
-		logger.Log(ERROR, "ERROR", "Failed to store processing results", map[string]interface{}{
-			"fileId": fileID,
-			"error":  err.Error(),
-		})
-		return err
-	}
-
-	logger.Log(DEBUG, "DEBUG", "Processing results stored", map[string]interface{}{
-		"fileId": fileID,
-		"table":  resultsTable,
-	})
-	return nil
-}
-
-func attributeValueFromInterface(v interface{}) (dynamodbTypes.AttributeValue, error) {
-	switch value := v.(type) {
-	case string:
-		return &dynamodbTypes.AttributeValueMemberS{Value: value}, nil
-	case int:
-		return &dynamodbTypes.AttributeValueMemberN{Value: fmt.Sprintf("%d", value)}, nil
-	case int64:
-		return &dynamodbTypes.AttributeValueMemberN{Value: fmt.Sprintf("%d", value)}, nil
-	case float32:
-		return &dynamodbTypes.AttributeValueMemberN{Value: fmt.Sprintf("%f", value)}, nil
-	case float64:
-		return &dynamodbTypes.AttributeValueMemberN{Value: fmt.Sprintf("%f", value)}, nil
-	case bool:
-		return &dynamodbTypes.AttributeValueMemberBOOL{Value: value}, nil
-	case []string:
-		items := make([]dynamodbTypes.AttributeValue, len(value))
-		for i, s := range value {
-			items[i] = &dynamodbTypes.AttributeValueMemberS{Value: s}
-		}
-		return &dynamodbTypes.AttributeValueMemberL{Value: items}, nil
-	case map[string]interface{}:
-		m := make(map[string]dynamodbTypes.AttributeValue)
-		for k, v := range value {
-			attr, err := attributeValueFromInterface(v)
-			if err != nil {
-				continue
-			}
-			m[k] = attr
-		}
-		return &dynamodbTypes.AttributeValueMemberM{Value: m}, nil
-	default:
-		// For complex types, marshal to JSON
-		jsonBytes, err := json.Marshal(value)
-		if err != nil {
-			return nil, err
-		}
-		return &dynamodbTypes.AttributeValueMemberS{Value: string(jsonBytes)}, nil
-	}
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/expression"
+	"github.com/aws/aws-sdk-go-v2/service/comprehend"
+	comprehendTypes "github.com/aws/aws-sdk-go-v2/service/comprehend/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodbTypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/textract"
+	textractTypes "github.com/aws/aws-sdk-go-v2/service/textract/types"
+	"golang.org/x/sync/errgroup"
+)
+
+// Logger configuration
+type LogLevel int
+
+const (
+	ERROR LogLevel = iota
+	WARN
+	INFO
+	DEBUG
+)
+
+type Logger struct {
+	level LogLevel
+	mu    sync.Mutex
+}
+
+type LogEntry struct {
+	Timestamp  string                 `json:"timestamp"`
+	Level      string                 `json:"level"`
+	Message    string                 `json:"message"`
+	BatchJobID string                 `json:"batchJobId,omitempty"`
+	FileID     string                 `json:"fileId,omitempty"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+}
+
+var logger *Logger
+
+func NewLogger() *Logger {
+	levelStr := os.Getenv("LOG_LEVEL")
+	if levelStr == "" {
+		levelStr = "INFO"
+	}
+
+	level := INFO
+	switch strings.ToUpper(levelStr) {
+	case "ERROR":
+		level = ERROR
+	case "WARN":
+		level = WARN
+	case "DEBUG":
+		level = DEBUG
+	}
+
+	return &Logger{level: level}
+}
+
+func (l *Logger) Log(level LogLevel, levelStr, message string, data map[string]interface{}) {
+	if level > l.level {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry := LogEntry{
+		Timestamp:  time.Now().UTC().Format(time.RFC3339),
+		Level:      levelStr,
+		Message:    message,
+		BatchJobID: os.Getenv("AWS_BATCH_JOB_ID"),
+		FileID:     os.Getenv("FILE_ID"),
+		Data:       data,
+	}
+
+	jsonEntry, _ := json.Marshal(entry)
+	fmt.Println(string(jsonEntry))
+}
+
+// Processing structures
+type TextractResult struct {
+	Text       string
+	WordCount  int
+	LineCount  int
+	Confidence float32
+	JobID      string
+	Engine     string
+	Duration   float64
+}
+
+type FormattedText struct {
+	Formatted  string      `dynamodbav:"formatted"`
+	Paragraphs []Paragraph `dynamodbav:"paragraphs"`
+	Stats      TextStats   `dynamodbav:"stats"`
+}
+
+type Paragraph struct {
+	Text      string `json:"text" dynamodbav:"text"`
+	Type      string `json:"type" dynamodbav:"type"`
+	WordCount int    `json:"wordCount" dynamodbav:"wordCount"`
+	CharCount int    `json:"charCount" dynamodbav:"charCount"`
+}
+
+type TextStats struct {
+	ParagraphCount   int `json:"paragraphCount" dynamodbav:"paragraphCount"`
+	SentenceCount    int `json:"sentenceCount" dynamodbav:"sentenceCount"`
+	CleanedChars     int `json:"cleanedChars" dynamodbav:"cleanedChars"`
+	OriginalChars    int `json:"originalChars" dynamodbav:"originalChars"`
+	ReductionPercent int `json:"reductionPercent" dynamodbav:"reductionPercent"`
+}
+
+// ComprehendResult is tagged with both json (API responses) and dynamodbav
+// (results-table storage via attributevalue.MarshalMap) struct tags so it
+// round-trips through DynamoDB without losing numeric/bool type fidelity.
+type ComprehendResult struct {
+	Language           string                          `json:"language" dynamodbav:"language"`
+	LanguageScore      float32                         `json:"languageScore" dynamodbav:"languageScore"`
+	Sentiment          *SentimentResult                `json:"sentiment,omitempty" dynamodbav:"sentiment,omitempty"`
+	PerChunkSentiments []PerChunkSentiment             `json:"perChunkSentiments,omitempty" dynamodbav:"perChunkSentiments,omitempty"`
+	Entities           []EntityResult                  `json:"entities" dynamodbav:"entities"`
+	EntitySummary      map[string][]EntitySummaryItem  `json:"entitySummary" dynamodbav:"entitySummary"`
+	EntityStats        EntityStats                     `json:"entityStats" dynamodbav:"entityStats"`
+	KeyPhrases         []KeyPhraseResult               `json:"keyPhrases" dynamodbav:"keyPhrases"`
+	Syntax             []SyntaxResult                  `json:"syntax" dynamodbav:"syntax"`
+	ProcessingTime     float64                         `json:"processingTime" dynamodbav:"processingTime"`
+	AnalyzedTextLength int                             `json:"analyzedTextLength" dynamodbav:"analyzedTextLength"`
+	OriginalTextLength int                             `json:"originalTextLength" dynamodbav:"originalTextLength"`
+	Truncated          bool                            `json:"truncated" dynamodbav:"truncated"`
+	Error              string                          `json:"error,omitempty" dynamodbav:"error,omitempty"`
+	ChunkCount         int                             `json:"chunkCount,omitempty" dynamodbav:"chunkCount,omitempty"`
+	MergeStrategy      string                          `json:"mergeStrategy,omitempty" dynamodbav:"mergeStrategy,omitempty"`
+	ContainsPII        bool                            `json:"containsPii,omitempty" dynamodbav:"containsPii,omitempty"`
+	PIIEntities        []PIIEntityResult               `json:"piiEntities,omitempty" dynamodbav:"piiEntities,omitempty"`
+	Warnings           []string                        `json:"warnings,omitempty" dynamodbav:"warnings,omitempty"`
+	CallLatencies      map[string]float64              `json:"callLatencies,omitempty" dynamodbav:"callLatencies,omitempty"`
+}
+
+type SentimentResult struct {
+	Sentiment      string                          `json:"Sentiment" dynamodbav:"Sentiment"`
+	SentimentScore comprehendTypes.SentimentScore `json:"SentimentScore" dynamodbav:"SentimentScore"`
+}
+
+// PerChunkSentiment is one chunk's DetectSentiment result, kept alongside
+// the weighted-average SentimentResult so callers can see how sentiment
+// varies across a long document instead of only its overall average.
+type PerChunkSentiment struct {
+	Sentiment      string                          `json:"Sentiment" dynamodbav:"Sentiment"`
+	SentimentScore comprehendTypes.SentimentScore `json:"SentimentScore" dynamodbav:"SentimentScore"`
+}
+
+type EntityResult struct {
+	Text         string  `json:"Text" dynamodbav:"Text"`
+	Type         string  `json:"Type" dynamodbav:"Type"`
+	Score        float32 `json:"Score" dynamodbav:"Score"`
+	BeginOffset  int32   `json:"BeginOffset" dynamodbav:"BeginOffset"`
+	EndOffset    int32   `json:"EndOffset" dynamodbav:"EndOffset"`
+	Length       int32   `json:"Length" dynamodbav:"Length"`
+	Category     string  `json:"Category" dynamodbav:"Category"`
+	Confidence   string  `json:"Confidence" dynamodbav:"Confidence"`
+}
+
+type EntitySummaryItem struct {
+	Text       string  `json:"text" dynamodbav:"text"`
+	Score      float32 `json:"score" dynamodbav:"score"`
+	Confidence string  `json:"confidence" dynamodbav:"confidence"`
+}
+
+type EntityStats struct {
+	TotalEntities          int      `json:"totalEntities" dynamodbav:"totalEntities"`
+	UniqueTypes            []string `json:"uniqueTypes" dynamodbav:"uniqueTypes"`
+	HighConfidenceEntities int      `json:"highConfidenceEntities" dynamodbav:"highConfidenceEntities"`
+	Categories             []string `json:"categories" dynamodbav:"categories"`
+}
+
+type KeyPhraseResult struct {
+	Text        string  `json:"Text" dynamodbav:"Text"`
+	Score       float32 `json:"Score" dynamodbav:"Score"`
+	BeginOffset int32   `json:"BeginOffset" dynamodbav:"BeginOffset"`
+	EndOffset   int32   `json:"EndOffset" dynamodbav:"EndOffset"`
+}
+
+type SyntaxResult struct {
+	Text         string  `json:"Text" dynamodbav:"Text"`
+	PartOfSpeech string  `json:"PartOfSpeech" dynamodbav:"PartOfSpeech"`
+	Score        float32 `json:"Score" dynamodbav:"Score"`
+	BeginOffset  int32   `json:"BeginOffset" dynamodbav:"BeginOffset"`
+	EndOffset    int32   `json:"EndOffset" dynamodbav:"EndOffset"`
+}
+
+// AWS clients
+var (
+	s3Client         *s3.Client
+	dynamoClient     DynamoDBAPI
+	textractClient   *textract.Client
+	comprehendClient *comprehend.Client
+	snsClient        *sns.Client
+	sqsClient        *sqs.Client
+	objectStore      ObjectStore
+)
+
+func init() {
+	logger = NewLogger()
+}
+
+func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if deadline, ok := jobDeadlineDuration(); ok {
+		var deadlineCancel context.CancelFunc
+		ctx, deadlineCancel = context.WithDeadline(ctx, time.Now().Add(deadline))
+		defer deadlineCancel()
+		logger.Log(INFO, "INFO", "Job deadline configured", map[string]interface{}{"deadlineSeconds": deadline.Seconds()})
+	}
+
+	// Set up signal handling. Cancelling ctx (rather than exiting the
+	// process outright) lets in-flight work such as the streaming
+	// Textract pagination in processFileWithTextract notice ctx.Done()
+	// and unwind instead of being killed mid-page.
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT)
+
+	go func() {
+		sig := <-sigChan
+		logger.Log(INFO, "INFO", fmt.Sprintf("Received signal: %v, shutting down gracefully", sig), nil)
+		cancel()
+	}()
+
+	// Log startup info
+	isDev := os.Getenv("NODE_ENV") == "development"
+	if isDev || logger.level == DEBUG {
+		logger.Log(DEBUG, "DEBUG", "Container startup debug info", map[string]interface{}{
+			"goVersion": strings.TrimPrefix(strings.TrimSpace(strings.Split(os.Args[0], " ")[0]), "go"),
+			"environment": map[string]string{
+				"AWS_BATCH_JOB_ID": os.Getenv("AWS_BATCH_JOB_ID"),
+				"S3_BUCKET":        os.Getenv("S3_BUCKET"),
+				"S3_KEY":           os.Getenv("S3_KEY"),
+				"FILE_ID":          os.Getenv("FILE_ID"),
+				"DYNAMODB_TABLE":   os.Getenv("DYNAMODB_TABLE"),
+				"AWS_REGION":       os.Getenv("AWS_REGION"),
+			},
+		})
+	} else {
+		hasRequiredEnvVars := os.Getenv("S3_BUCKET") != "" && os.Getenv("S3_KEY") != "" &&
+			os.Getenv("FILE_ID") != "" && os.Getenv("DYNAMODB_TABLE") != ""
+		logger.Log(INFO, "INFO", "OCR Processor starting - batch mode only", map[string]interface{}{
+			"hasRequiredEnvVars": hasRequiredEnvVars,
+		})
+	}
+
+	// Initialize AWS clients
+	if err := initializeAWSClients(ctx); err != nil {
+		logger.Log(ERROR, "ERROR", "Failed to initialize AWS clients", map[string]interface{}{
+			"error": err.Error(),
+		})
+		os.Exit(1)
+	}
+
+	// Run batch job with small delay for logging setup
+	time.Sleep(100 * time.Millisecond)
+	if err := runBatchJob(ctx); err != nil {
+		logger.Log(ERROR, "ERROR", "Batch job failed", map[string]interface{}{
+			"error": err.Error(),
+		})
+		os.Exit(1)
+	}
+}
+
+func initializeAWSClients(ctx context.Context) error {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to load SDK config: %w", err)
+	}
+
+	s3Client = s3.NewFromConfig(cfg)
+	dynamoClient, err = newDynamoDBClient(cfg)
+	if err != nil {
+		return fmt.Errorf("unable to initialize DynamoDB client: %w", err)
+	}
+	textractClient = textract.NewFromConfig(cfg)
+	comprehendClient = comprehend.NewFromConfig(cfg)
+	snsClient = sns.NewFromConfig(cfg)
+	sqsClient = sqs.NewFromConfig(cfg)
+	ocrRouter = NewEngineRouter()
+
+	objectStore, err = NewObjectStore(cfg)
+	if err != nil {
+		return fmt.Errorf("unable to initialize object store: %w", err)
+	}
+
+	return nil
+}
+
+func runBatchJob(ctx context.Context) error {
+	// Validate required environment variables
+	requiredVars := []string{"S3_BUCKET", "S3_KEY", "FILE_ID", "DYNAMODB_TABLE"}
+	var missingVars []string
+	for _, v := range requiredVars {
+		if os.Getenv(v) == "" {
+			missingVars = append(missingVars, v)
+		}
+	}
+
+	if len(missingVars) > 0 {
+		logger.Log(ERROR, "ERROR", "Missing required environment variables", map[string]interface{}{
+			"missingVars": missingVars,
+		})
+		return fmt.Errorf("missing required environment variables: %v", missingVars)
+	}
+
+	logger.Log(INFO, "INFO", "Starting batch processing", map[string]interface{}{
+		"batchJobId": os.Getenv("AWS_BATCH_JOB_ID"),
+		"jobQueue":   os.Getenv("AWS_BATCH_JQ_NAME"),
+	})
+
+	result, err := processS3File(ctx)
+	if err != nil {
+		return err
+	}
+
+	if partial, ok := result["partial"].(bool); ok && partial {
+		logger.Log(WARN, "WARN", "Batch job exited early: deadline exceeded, partial result persisted", map[string]interface{}{
+			"batchJobId": os.Getenv("AWS_BATCH_JOB_ID"),
+		})
+		return nil
+	}
+
+	logger.Log(INFO, "INFO", "Batch job completed successfully", map[string]interface{}{
+		"processingDuration": result["processing_duration"],
+		"textExtracted":      result["analysis"].(map[string]interface{})["word_count"].(int) > 0,
+	})
+
+	return nil
+}
+
+func processS3File(ctx context.Context) (map[string]interface{}, error) {
+	bucketName := os.Getenv("S3_BUCKET")
+	objectKey := os.Getenv("S3_KEY")
+	fileID := os.Getenv("FILE_ID")
+	dynamoTable := os.Getenv("DYNAMODB_TABLE")
+
+	logger.Log(INFO, "INFO", "Starting file processing", map[string]interface{}{
+		"bucket": bucketName,
+		"key":    objectKey,
+		"fileId": fileID,
+		"table":  dynamoTable,
+	})
+
+	// Update status to processing. processing_started is a Unix-epoch
+	// Number, not an RFC3339 string, to match processing-status-index's
+	// sort key type - dead_job_detector's findStuckProcessingJobs queries
+	// that GSI with a numeric KeyConditionExpression.
+	if err := updateFileStatus(ctx, dynamoTable, fileID, "processing", map[string]interface{}{
+		"processing_started": time.Now().Unix(),
+		"batch_job_id":       os.Getenv("AWS_BATCH_JOB_ID"),
+	}); err != nil {
+		return nil, err
+	}
+	recordStageCheckpoint(ctx, fileID, "queued", true)
+
+	// Get file metadata
+	headInfo, err := objectStore.Head(ctx, bucketName, objectKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object metadata: %w", err)
+	}
+
+	fileSize := headInfo.ContentLength
+	contentType := headInfo.ContentType
+
+	logger.Log(INFO, "INFO", "File metadata retrieved", map[string]interface{}{
+		"size":         fileSize,
+		"contentType":  contentType,
+		"lastModified": headInfo.LastModified,
+	})
+
+	// Textract only understands S3 URIs. When the source object lives in a
+	// non-S3 store (e.g. MinIO), stage a copy into TEXTRACT_STAGING_BUCKET
+	// first and clean it up once OCR is done.
+	textractBucket, textractKey := bucketName, objectKey
+	stagingCleanup := func() {}
+	if objectStoreKind() != "s3" {
+		textractBucket, textractKey, stagingCleanup, err = stageToS3(ctx, bucketName, objectKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stage object for Textract: %w", err)
+		}
+	}
+	defer stagingCleanup()
+
+	// Process with the configured OCR engine (Textract, with optional
+	// Tesseract/PaddleOCR fallback on low confidence). Textract gets 60% of
+	// whatever time remains on the job deadline.
+	startTime := time.Now()
+	recordStageCheckpoint(ctx, fileID, "textract", false)
+	textractCtx, textractCancel := stageDeadline(ctx, 0.60)
+	extractedData, err := ocrRouter.Extract(textractCtx, fmt.Sprintf("s3://%s/%s", textractBucket, textractKey))
+	textractCancel()
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return persistPartialResult(fileID, dynamoTable, "", "textract stage deadline exceeded")
+		}
+		updateFileStatus(ctx, dynamoTable, fileID, "failed", map[string]interface{}{
+			"error_message": err.Error(),
+			"failed_at":     time.Now().UTC().Format(time.RFC3339),
+		})
+		return nil, err
+	}
+	textractTime := time.Since(startTime).Seconds()
+	recordStageCheckpoint(ctx, fileID, "textract", true)
+
+	logger.Log(INFO, "INFO", "Textract processing completed", map[string]interface{}{
+		"processingTimeSeconds": textractTime,
+		"wordCount":             extractedData.WordCount,
+		"lineCount":             extractedData.LineCount,
+		"confidence":            extractedData.Confidence,
+	})
+
+	// Format extracted text
+	var formattedTextData FormattedText
+	textForComprehend := extractedData.Text
+
+	if strings.TrimSpace(extractedData.Text) != "" {
+		logger.Log(INFO, "INFO", "Formatting extracted text", nil)
+		formattedTextData = formatExtractedText(extractedData.Text)
+		if formattedTextData.Formatted != "" {
+			textForComprehend = formattedTextData.Formatted
+		}
+
+		logger.Log(INFO, "INFO", "Text formatting completed", map[string]interface{}{
+			"originalChars": formattedTextData.Stats.OriginalChars,
+			"cleanedChars":  formattedTextData.Stats.CleanedChars,
+			"paragraphs":    formattedTextData.Stats.ParagraphCount,
+			"sentences":     formattedTextData.Stats.SentenceCount,
+			"reduction":     fmt.Sprintf("%d%%", formattedTextData.Stats.ReductionPercent),
+		})
+	}
+
+	// Process with Comprehend. Comprehend gets 30% of whatever time remains
+	// after Textract.
+	var comprehendData ComprehendResult
+	if strings.TrimSpace(textForComprehend) != "" {
+		logger.Log(INFO, "INFO", "Starting Comprehend analysis on formatted text", nil)
+		recordStageCheckpoint(ctx, fileID, "comprehend", false)
+		comprehendCtx, comprehendCancel := stageDeadline(ctx, 0.30)
+		comprehendStartTime := time.Now()
+		comprehendData = processTextWithComprehend(comprehendCtx, textForComprehend)
+		comprehendTime := time.Since(comprehendStartTime).Seconds()
+		comprehendDeadlineExceeded := errors.Is(comprehendCtx.Err(), context.DeadlineExceeded)
+		comprehendCancel()
+		if comprehendDeadlineExceeded {
+			return persistPartialResult(fileID, dynamoTable, extractedData.Text, "comprehend stage deadline exceeded")
+		}
+		recordStageCheckpoint(ctx, fileID, "comprehend", true)
+
+		logger.Log(INFO, "INFO", "Comprehend analysis completed", map[string]interface{}{
+			"processingTimeSeconds": comprehendTime,
+			"language":              comprehendData.Language,
+			"sentiment":             comprehendData.Sentiment,
+			"entitiesCount":         len(comprehendData.Entities),
+			"keyPhrasesCount":       len(comprehendData.KeyPhrases),
+		})
+	} else {
+		logger.Log(INFO, "INFO", "Skipping Comprehend analysis - no text extracted", nil)
+	}
+
+	totalProcessingTime := time.Since(startTime).Seconds()
+
+	// When PII was found, produce a redacted copy of the text and persist
+	// it to its own S3 key rather than mixing it into the source object.
+	var redactedText string
+	var redactedTextURI string
+	if comprehendData.ContainsPII && len(comprehendData.PIIEntities) > 0 {
+		redactedText = redactPII(textForComprehend, comprehendData.PIIEntities, piiRedactionPolicy())
+		uri, err := persistRedactedText(ctx, bucketName, fileID, redactedText)
+		if err != nil {
+			logger.Log(WARN, "WARN", "Failed to persist redacted text", map[string]interface{}{"fileId": fileID, "error": err.Error()})
+		} else {
+			redactedTextURI = uri
+		}
+	}
+
+	// Generate processing results
+	processingResults := map[string]interface{}{
+		"processed_at":     time.Now().UTC().Format(time.RFC3339),
+		"file_size":        fileSize,
+		"content_type":     contentType,
+		"processing_duration": fmt.Sprintf("%.2f seconds", totalProcessingTime),
+		"extracted_text":   extractedData.Text,
+		"formatted_text":   formattedTextData.Formatted,
+		"redacted_text":    redactedText,
+		"redacted_text_uri": redactedTextURI,
+		"text_formatting": map[string]interface{}{
+			"paragraphs":     formattedTextData.Paragraphs,
+			"stats":          formattedTextData.Stats,
+			"hasFormatting":  formattedTextData.Formatted != "",
+		},
+		"analysis": map[string]interface{}{
+			"word_count":      extractedData.WordCount,
+			"character_count": len(extractedData.Text),
+			"line_count":      extractedData.LineCount,
+			"confidence":      extractedData.Confidence,
+		},
+		"comprehend_analysis": comprehendData,
+		"metadata": map[string]interface{}{
+			"processor_version":   "2.2.0",
+			"batch_job_id":        os.Getenv("AWS_BATCH_JOB_ID"),
+			"ocr_engine":          extractedData.Engine,
+			"ocr_job_id":          extractedData.JobID,
+			"ocr_confidence":      extractedData.Confidence,
+			"textract_job_id":     extractedData.JobID,
+			"textract_duration":   fmt.Sprintf("%.2f seconds", textractTime),
+			"comprehend_duration": fmt.Sprintf("%.2f seconds", comprehendData.ProcessingTime),
+			"chunk_count":         comprehendData.ChunkCount,
+			"merge_strategy":      comprehendData.MergeStrategy,
+		},
+	}
+
+	// In strict mode, raw OCR text never reaches the results table once PII
+	// is found - only the redacted copy does.
+	if piiStrictModeEnabled() && comprehendData.ContainsPII && len(comprehendData.PIIEntities) > 0 {
+		processingResults["extracted_text"] = redactedText
+		processingResults["formatted_text"] = redactedText
+	}
+
+	recordStageCheckpoint(ctx, fileID, "formatting", true)
+
+	// Commit the results row and the "processed" status in one transaction,
+	// so a crash between the two writes can't leave the metadata row stuck
+	// on "processing" with results already stored, or vice versa.
+	if err := commitProcessingOutcome(ctx, dynamoTable, fileID, "processed", map[string]interface{}{
+		"processing_completed": time.Now().UTC().Format(time.RFC3339),
+		"processing_duration":  processingResults["processing_duration"],
+	}, processingResults); err != nil {
+		return nil, err
+	}
+
+	logger.Log(INFO, "INFO", "File processing completed successfully", map[string]interface{}{
+		"processingTimeSeconds": totalProcessingTime,
+		"extractedWords":        extractedData.WordCount,
+		"extractedLines":        extractedData.LineCount,
+		"confidence":            extractedData.Confidence,
+		"comprehendLanguage":    comprehendData.Language,
+		"comprehendSentiment":   comprehendData.Sentiment,
+	})
+
+	return processingResults, nil
+}
+
+func processFileWithTextract(ctx context.Context, bucketName, objectKey string) (*TextractResult, error) {
+	logger.Log(INFO, "INFO", "Starting Textract document analysis", map[string]interface{}{
+		"s3Uri": fmt.Sprintf("s3://%s/%s", bucketName, objectKey),
+	})
+
+	// Start document analysis
+	startInput := &textract.StartDocumentAnalysisInput{
+		DocumentLocation: &textractTypes.DocumentLocation{
+			S3Object: &textractTypes.S3Object{
+				Bucket: aws.String(bucketName),
+				Name:   aws.String(objectKey),
+			},
+		},
+		FeatureTypes: []textractTypes.FeatureType{
+			textractTypes.FeatureTypeTables,
+			textractTypes.FeatureTypeForms,
+		},
+	}
+	if snsNotificationEnabled() {
+		startInput.NotificationChannel = &textractTypes.NotificationChannel{
+			SNSTopicArn: aws.String(os.Getenv("TEXTRACT_SNS_TOPIC_ARN")),
+			RoleArn:     aws.String(os.Getenv("TEXTRACT_SNS_ROLE_ARN")),
+		}
+	}
+
+	startResp, err := textractClient.StartDocumentAnalysis(ctx, startInput)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start Textract analysis: %w", err)
+	}
+
+	jobID := aws.ToString(startResp.JobId)
+	logger.Log(INFO, "INFO", "Textract job submitted", map[string]interface{}{
+		"textractJobId": jobID,
+	})
+
+	// Wait for completion: via SNS/SQS notification when configured,
+	// otherwise fall back to polling GetDocumentAnalysis every 5s.
+	if err := waitForTextractCompletion(ctx, jobID); err != nil {
+		return nil, err
+	}
+
+	// Stream results page by page instead of accumulating every Block in
+	// memory: a producer goroutine paginates GetDocumentAnalysis onto a
+	// bounded channel, and this goroutine consumes pages as they arrive,
+	// formatting and checkpointing progress every TEXTRACT_PAGE_WINDOW
+	// pages so large multi-hundred-page documents don't delay formatting
+	// until the very end (or balloon memory doing so).
+	streamBuffer := 4
+	if v := os.Getenv("TEXTRACT_STREAM_BUFFER"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			streamBuffer = parsed
+		}
+	}
+
+	pageWindow := 10
+	if v := os.Getenv("TEXTRACT_PAGE_WINDOW"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			pageWindow = parsed
+		}
+	}
+
+	pagesCh := make(chan []textractTypes.Block, streamBuffer)
+	pageErrCh := make(chan error, 1)
+
+	go func() {
+		defer close(pagesCh)
+		var nextToken *string
+		for {
+			resp, err := textractClient.GetDocumentAnalysis(ctx, &textract.GetDocumentAnalysisInput{
+				JobId:     aws.String(jobID),
+				NextToken: nextToken,
+			})
+			if err != nil {
+				pageErrCh <- fmt.Errorf("failed to get Textract results: %w", err)
+				return
+			}
+
+			select {
+			case pagesCh <- resp.Blocks:
+			case <-ctx.Done():
+				pageErrCh <- ctx.Err()
+				return
+			}
+
+			nextToken = resp.NextToken
+			if nextToken == nil {
+				pageErrCh <- nil
+				return
+			}
+		}
+	}()
+
+	var extractedText []string
+	var totalConfidence float32
+	confidenceCount := 0
+	pageCount := 0
+	totalBlocks := 0
+
+consumeLoop:
+	for {
+		select {
+		case blocks, ok := <-pagesCh:
+			if !ok {
+				break consumeLoop
+			}
+
+			pageCount++
+			totalBlocks += len(blocks)
+
+			for _, block := range blocks {
+				if block.BlockType == textractTypes.BlockTypeLine && block.Text != nil {
+					extractedText = append(extractedText, aws.ToString(block.Text))
+					if block.Confidence != nil {
+						totalConfidence += aws.ToFloat32(block.Confidence)
+						confidenceCount++
+					}
+				}
+			}
+
+			if pageCount%pageWindow == 0 {
+				formatExtractedText(strings.Join(extractedText, "\n"))
+				updateProcessingProgress(ctx, os.Getenv("FILE_ID"), pageCount, len(extractedText))
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	if err := <-pageErrCh; err != nil {
+		return nil, err
+	}
+
+	logger.Log(DEBUG, "DEBUG", "Textract results retrieved", map[string]interface{}{
+		"totalBlocks": totalBlocks,
+		"pages":       pageCount,
+	})
+
+	fullText := strings.Join(extractedText, "\n")
+	words := strings.Fields(fullText)
+
+	avgConfidence := float32(0)
+	if confidenceCount > 0 {
+		avgConfidence = totalConfidence / float32(confidenceCount)
+	}
+
+	return &TextractResult{
+		Text:       fullText,
+		WordCount:  len(words),
+		LineCount:  len(extractedText),
+		Confidence: avgConfidence,
+		JobID:      jobID,
+		Engine:     "textract",
+	}, nil
+}
+
+func formatExtractedText(rawText string) FormattedText {
+	if rawText == "" {
+		return FormattedText{
+			Formatted:  "",
+			Paragraphs: []Paragraph{},
+			Stats: TextStats{
+				ParagraphCount: 0,
+				SentenceCount:  0,
+				CleanedChars:   0,
+			},
+		}
+	}
+
+	// Fix URLs and emails
+	preprocessed := fixURLsAndEmails(rawText)
+
+	// Continue with other preprocessing
+	preprocessed = regexp.MustCompile(`\.\s+([A-Z])`).ReplaceAllString(preprocessed, ". $1")
+	preprocessed = regexp.MustCompile(`([a-z])\s+([A-Z])`).ReplaceAllString(preprocessed, "$1 $2")
+	preprocessed = regexp.MustCompile(`(\w)\s+([,.])`).ReplaceAllString(preprocessed, "$1$2")
+	preprocessed = regexp.MustCompile(`([,.!?;:])\s*`).ReplaceAllString(preprocessed, "$1 ")
+	preprocessed = regexp.MustCompile(`\n{4,}`).ReplaceAllString(preprocessed, "\n\n\n")
+	preprocessed = strings.ReplaceAll(preprocessed, "\r", "")
+	preprocessed = strings.ReplaceAll(preprocessed, "\t", " ")
+
+	// Smart line joining
+	lines := strings.Split(preprocessed, "\n")
+	var processedLines []string
+	currentLine := ""
+
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+
+		if line == "" {
+			if currentLine != "" {
+				processedLines = append(processedLines, currentLine)
+				currentLine = ""
+			}
+			processedLines = append(processedLines, "")
+			continue
+		}
+
+		isVeryShort := len(line) < 20
+		endsWithPunctuation := regexp.MustCompile(`[.!?]$`).MatchString(currentLine)
+		startsWithCapital := regexp.MustCompile(`^[A-Z]`).MatchString(line)
+		looksLikeHeading := len(line) < 40 && line == strings.ToUpper(line)
+
+		if currentLine != "" && !endsWithPunctuation && !startsWithCapital && !looksLikeHeading && !isVeryShort {
+			currentLine += " " + line
+		} else {
+			if currentLine != "" {
+				processedLines = append(processedLines, currentLine)
+			}
+			currentLine = line
+		}
+	}
+
+	if currentLine != "" {
+		processedLines = append(processedLines, currentLine)
+	}
+
+	// Create paragraphs
+	var paragraphs []Paragraph
+	var currentParagraph []string
+
+	for _, line := range processedLines {
+		if line == "" {
+			if len(currentParagraph) > 0 {
+				text := strings.Join(currentParagraph, " ")
+				text = strings.TrimSpace(text)
+				if text != "" {
+					paragraphs = append(paragraphs, Paragraph{
+						Text:      text,
+						Type:      "paragraph",
+						WordCount: len(strings.Fields(text)),
+						CharCount: len(text),
+					})
+				}
+				currentParagraph = []string{}
+			}
+		} else {
+			currentParagraph = append(currentParagraph, line)
+		}
+	}
+
+	if len(currentParagraph) > 0 {
+		text := strings.Join(currentParagraph, " ")
+		text = strings.TrimSpace(text)
+		if text != "" {
+			paragraphs = append(paragraphs, Paragraph{
+				Text:      text,
+				Type:      "paragraph",
+				WordCount: len(strings.Fields(text)),
+				CharCount: len(text),
+			})
+		}
+	}
+
+	// Create formatted output
+	var formattedParts []string
+	for _, p := range paragraphs {
+		formattedParts = append(formattedParts, p.Text)
+	}
+	formatted := strings.Join(formattedParts, "\n\n")
+
+	// Final cleanup
+	formatted = fixURLsAndEmails(formatted)
+	formatted = regexp.MustCompile(`\s+([,.!?;:])`).ReplaceAllString(formatted, "$1")
+	formatted = regexp.MustCompile(`([,.!?;:])(?!\s|$)`).ReplaceAllString(formatted, "$1 ")
+	formatted = regexp.MustCompile(` {2,}`).ReplaceAllString(formatted, " ")
+	formatted = strings.TrimSpace(formatted)
+
+	// Calculate stats
+	sentences := regexp.MustCompile(`[.!?]+`).FindAllString(formatted, -1)
+	stats := TextStats{
+		ParagraphCount:   len(paragraphs),
+		SentenceCount:    len(sentences),
+		CleanedChars:     len(formatted),
+		OriginalChars:    len(rawText),
+		ReductionPercent: int(float64(len(rawText)-len(formatted)) / float64(len(rawText)) * 100),
+	}
+
+	return FormattedText{
+		Formatted:  formatted,
+		Paragraphs: paragraphs,
+		Stats:      stats,
+	}
+}
+
+func fixURLsAndEmails(text string) string {
+	// Fix emails
+	emailRegex := regexp.MustCompile(`(\w+)\s*@\s*([^\s\n\r\t]+)`)
+	text = emailRegex.ReplaceAllStringFunc(text, func(match string) string {
+		parts := strings.Split(match, "@")
+		if len(parts) == 2 {
+			user := strings.TrimSpace(parts[0])
+			domain := strings.ReplaceAll(parts[1], " ", "")
+			domain = regexp.MustCompile(`\.\s+`).ReplaceAllString(domain, ".")
+			return user + "@" + domain
+		}
+		return match
+	})
+
+	// Fix URLs starting with www.
+	wwwRegex := regexp.MustCompile(`www\.\s+([^\s\n\r\t]+?)(\s+(?:I|,|\||$))`)
+	text = wwwRegex.ReplaceAllStringFunc(text, func(match string) string {
+		urlPart := regexp.MustCompile(`www\.\s+`).ReplaceAllString(match, "www.")
+		urlPart = regexp.MustCompile(`\.\s+`).ReplaceAllString(urlPart, ".")
+		urlPart = regexp.MustCompile(`\s+\.`).ReplaceAllString(urlPart, ".")
+		return strings.ReplaceAll(urlPart, " ", "")
+	})
+
+	// Fix domain patterns
+	text = regexp.MustCompile(`(\w+)\.\s+(\w+)\.\s+(\w+)(?:\s|$|[^\w])`).ReplaceAllString(text, "$1.$2.$3")
+	text = regexp.MustCompile(`(\w+)\.\s+(\w+)(?:\s|$|[^\w])`).ReplaceAllString(text, "$1.$2")
+
+	// Fix http:// and https://
+	text = regexp.MustCompile(`https?\s*:\s*\/\s*\/\s*`).ReplaceAllStringFunc(text, func(match string) string {
+		return strings.ReplaceAll(match, " ", "")
+	})
+
+	// Fix TLDs
+	tldRegex := regexp.MustCompile(`(\S+)\.\s+(\w{2,3})(?:\s|$|[^\w])`)
+	text = tldRegex.ReplaceAllStringFunc(text, func(match string) string {
+		parts := regexp.MustCompile(`\.\s+`).Split(match, -1)
+		if len(parts) == 2 {
+			domain := parts[0]
+			tld := strings.TrimSpace(parts[1])
+			if regexp.MustCompile(`^(com|net|org|edu|gov|mil|int|nz|au|uk|us|ca|de|fr|jp|cn|io|co|me|info|biz)$`).MatchString(strings.ToLower(tld)) {
+				return domain + "." + tld
+			}
+		}
+		return match
+	})
+
+	return text
+}
+
+func getEntityCategory(entityType string) string {
+	categories := map[string]string{
+		"PERSON":          "People",
+		"LOCATION":        "Places",
+		"ORGANIZATION":    "Organizations",
+		"COMMERCIAL_ITEM": "Products & Services",
+		"EVENT":           "Events",
+		"DATE":            "Dates & Times",
+		"QUANTITY":        "Numbers & Quantities",
+		"TITLE":           "Titles & Positions",
+		"OTHER":           "Other",
+	}
+
+	if category, ok := categories[entityType]; ok {
+		return category
+	}
+	return "Other"
+}
+
+func processTextWithComprehend(ctx context.Context, text string) ComprehendResult {
+	const maxLength = 5000
+	if len(text) > maxLength {
+		return processLongTextWithComprehend(ctx, text)
+	}
+
+	textToAnalyze := text
+
+	logger.Log(INFO, "INFO", "Starting Comprehend analysis", map[string]interface{}{
+		"originalLength": len(text),
+		"analyzedLength": len(textToAnalyze),
+		"truncated":      false,
+	})
+
+	startTime := time.Now()
+	result := ComprehendResult{
+		OriginalTextLength: len(text),
+		AnalyzedTextLength: len(textToAnalyze),
+		Truncated:          false,
+		CallLatencies:      make(map[string]float64),
+	}
+
+	// Language detection has to run first since every other call below
+	// needs its LanguageCode, so it stays outside the fan-out.
+	langStart := time.Now()
+	langResp, err := comprehendClient.DetectDominantLanguage(ctx, &comprehend.DetectDominantLanguageInput{
+		Text: aws.String(textToAnalyze),
+	}, withComprehendRetry())
+	result.CallLatencies["detectDominantLanguage"] = time.Since(langStart).Seconds()
+	if err != nil {
+		logger.Log(WARN, "WARN", "Language detection failed", map[string]interface{}{
+			"error": err.Error(),
+		})
+		result.Warnings = append(result.Warnings, fmt.Sprintf("language detection: %v", err))
+		result.Language = "unknown"
+		result.LanguageScore = 0
+	} else if len(langResp.Languages) > 0 {
+		result.Language = aws.ToString(langResp.Languages[0].LanguageCode)
+		result.LanguageScore = aws.ToFloat32(langResp.Languages[0].Score)
+	}
+
+	langCode := result.Language
+	if langCode == "unknown" {
+		langCode = "en"
+	}
+
+	// Sentiment, entities, key phrases, and syntax are independent of one
+	// another once langCode is known, so they run concurrently instead of
+	// serially waiting on ~4x the combined latency. A mutex guards result:
+	// each goroutine sets its own fields, but all of them also write the
+	// shared CallLatencies map and Warnings slice. Each branch records its own
+	// failure as a Warning and returns nil so one throttled call can't cancel
+	// the other three - the same "partial failure is OK" contract the
+	// sequential version had.
+	var mu sync.Mutex
+	group, groupCtx := errgroup.WithContext(ctx)
+
+	group.Go(func() error {
+		start := time.Now()
+		sentResp, sentErr := comprehendClient.DetectSentiment(groupCtx, &comprehend.DetectSentimentInput{
+			Text:         aws.String(textToAnalyze),
+			LanguageCode: comprehendTypes.LanguageCode(langCode),
+		}, withComprehendRetry())
+
+		mu.Lock()
+		defer mu.Unlock()
+		result.CallLatencies["detectSentiment"] = time.Since(start).Seconds()
+		if sentErr != nil {
+			logger.Log(WARN, "WARN", "Sentiment analysis failed", map[string]interface{}{
+				"error": sentErr.Error(),
+			})
+			result.Warnings = append(result.Warnings, fmt.Sprintf("sentiment: %v", sentErr))
+			return nil
+		}
+		result.Sentiment = &SentimentResult{
+			Sentiment:      string(sentResp.Sentiment),
+			SentimentScore: *sentResp.SentimentScore,
+		}
+		return nil
+	})
+
+	group.Go(func() error {
+		start := time.Now()
+		entResp, entErr := comprehendClient.DetectEntities(groupCtx, &comprehend.DetectEntitiesInput{
+			Text:         aws.String(textToAnalyze),
+			LanguageCode: comprehendTypes.LanguageCode(langCode),
+		}, withComprehendRetry())
+
+		mu.Lock()
+		defer mu.Unlock()
+		result.CallLatencies["detectEntities"] = time.Since(start).Seconds()
+		if entErr != nil {
+			logger.Log(WARN, "WARN", "Entity detection failed", map[string]interface{}{
+				"error": entErr.Error(),
+			})
+			result.Warnings = append(result.Warnings, fmt.Sprintf("entities: %v", entErr))
+			result.Entities = []EntityResult{}
+			result.EntitySummary = make(map[string][]EntitySummaryItem)
+			return nil
+		}
+
+		result.Entities = make([]EntityResult, len(entResp.Entities))
+		result.EntitySummary = make(map[string][]EntitySummaryItem)
+		uniqueTypes := make(map[string]bool)
+		categories := make(map[string]bool)
+		highConfidence := 0
+
+		for i, entity := range entResp.Entities {
+			confidence := "Low"
+			if aws.ToFloat32(entity.Score) >= 0.8 {
+				confidence = "High"
+				highConfidence++
+			} else if aws.ToFloat32(entity.Score) >= 0.5 {
+				confidence = "Medium"
+			}
+
+			entityType := string(entity.Type)
+			category := getEntityCategory(entityType)
+
+			result.Entities[i] = EntityResult{
+				Text:        aws.ToString(entity.Text),
+				Type:        entityType,
+				Score:       aws.ToFloat32(entity.Score),
+				BeginOffset: aws.ToInt32(entity.BeginOffset),
+				EndOffset:   aws.ToInt32(entity.EndOffset),
+				Length:      aws.ToInt32(entity.EndOffset) - aws.ToInt32(entity.BeginOffset),
+				Category:    category,
+				Confidence:  confidence,
+			}
+
+			uniqueTypes[entityType] = true
+			categories[category] = true
+
+			if _, ok := result.EntitySummary[entityType]; !ok {
+				result.EntitySummary[entityType] = []EntitySummaryItem{}
+			}
+			result.EntitySummary[entityType] = append(result.EntitySummary[entityType], EntitySummaryItem{
+				Text:       aws.ToString(entity.Text),
+				Score:      aws.ToFloat32(entity.Score),
+				Confidence: confidence,
+			})
+		}
+
+		var uniqueTypesList []string
+		for t := range uniqueTypes {
+			uniqueTypesList = append(uniqueTypesList, t)
+		}
+		var categoriesList []string
+		for c := range categories {
+			categoriesList = append(categoriesList, c)
+		}
+
+		result.EntityStats = EntityStats{
+			TotalEntities:          len(result.Entities),
+			UniqueTypes:            uniqueTypesList,
+			HighConfidenceEntities: highConfidence,
+			Categories:             categoriesList,
+		}
+		return nil
+	})
+
+	group.Go(func() error {
+		start := time.Now()
+		keyResp, keyErr := comprehendClient.DetectKeyPhrases(groupCtx, &comprehend.DetectKeyPhrasesInput{
+			Text:         aws.String(textToAnalyze),
+			LanguageCode: comprehendTypes.LanguageCode(langCode),
+		}, withComprehendRetry())
+
+		mu.Lock()
+		defer mu.Unlock()
+		result.CallLatencies["detectKeyPhrases"] = time.Since(start).Seconds()
+		if keyErr != nil {
+			logger.Log(WARN, "WARN", "Key phrases extraction failed", map[string]interface{}{
+				"error": keyErr.Error(),
+			})
+			result.Warnings = append(result.Warnings, fmt.Sprintf("key phrases: %v", keyErr))
+			result.KeyPhrases = []KeyPhraseResult{}
+			return nil
+		}
+
+		result.KeyPhrases = make([]KeyPhraseResult, len(keyResp.KeyPhrases))
+		for i, phrase := range keyResp.KeyPhrases {
+			result.KeyPhrases[i] = KeyPhraseResult{
+				Text:        aws.ToString(phrase.Text),
+				Score:       aws.ToFloat32(phrase.Score),
+				BeginOffset: aws.ToInt32(phrase.BeginOffset),
+				EndOffset:   aws.ToInt32(phrase.EndOffset),
+			}
+		}
+		return nil
+	})
+
+	group.Go(func() error {
+		start := time.Now()
+		synResp, synErr := comprehendClient.DetectSyntax(groupCtx, &comprehend.DetectSyntaxInput{
+			Text:         aws.String(textToAnalyze),
+			LanguageCode: comprehendTypes.SyntaxLanguageCode(langCode),
+		}, withComprehendRetry())
+
+		mu.Lock()
+		defer mu.Unlock()
+		result.CallLatencies["detectSyntax"] = time.Since(start).Seconds()
+		if synErr != nil {
+			logger.Log(WARN, "WARN", "Syntax analysis failed", map[string]interface{}{
+				"error": synErr.Error(),
+			})
+			result.Warnings = append(result.Warnings, fmt.Sprintf("syntax: %v", synErr))
+			result.Syntax = []SyntaxResult{}
+			return nil
+		}
+
+		result.Syntax = make([]SyntaxResult, len(synResp.SyntaxTokens))
+		for i, token := range synResp.SyntaxTokens {
+			result.Syntax[i] = SyntaxResult{
+				Text:         aws.ToString(token.Text),
+				PartOfSpeech: string(token.PartOfSpeech.Tag),
+				Score:        aws.ToFloat32(token.PartOfSpeech.Score),
+				BeginOffset:  aws.ToInt32(token.BeginOffset),
+				EndOffset:    aws.ToInt32(token.EndOffset),
+			}
+		}
+		return nil
+	})
+
+	// None of the goroutines above return a non-nil error - each reports its
+	// own failure via result.Warnings - so group.Wait() only ever blocks
+	// until all four finish.
+	_ = group.Wait()
+
+	// PII detection - ContainsPiiEntities gates the more expensive
+	// DetectPiiEntities call, since most documents contain no PII at all.
+	piiEntities, containsPII, err := detectPII(ctx, textToAnalyze, langCode)
+	if err != nil {
+		logger.Log(WARN, "WARN", "PII detection failed", map[string]interface{}{
+			"error": err.Error(),
+		})
+		result.Warnings = append(result.Warnings, fmt.Sprintf("pii detection: %v", err))
+	} else {
+		result.ContainsPII = containsPII
+		result.PIIEntities = piiEntities
+	}
+
+	result.ProcessingTime = time.Since(startTime).Seconds()
+	return result
+}
+
+// withComprehendRetry applies the SDK's standard Retryer (jittered
+// exponential backoff) capped at 5 attempts, with ThrottlingException and
+// TooManyRequestsException added to the error codes it already retries,
+// shared by every Detect* call in this file so a
+// throttled document retries instead of immediately failing that subresult.
+func withComprehendRetry() func(*comprehend.Options) {
+	return func(o *comprehend.Options) {
+		var retryer aws.Retryer = retry.NewStandard()
+		retryer = retry.AddWithMaxAttempts(retryer, 5)
+		retryer = retry.AddWithErrorCodes(retryer, "ThrottlingException", "TooManyRequestsException")
+		o.Retryer = retryer
+	}
+}
+
+func updateFileStatus(ctx context.Context, tableName, fileID, status string, additionalData map[string]interface{}) error {
+	uploadTimestamp, err := resolveUploadTimestamp(ctx, tableName, fileID)
+	if err != nil {
+		return err
+	}
+
+	// Build the update via expression.UpdateBuilder rather than hand-formatted
+	// "key = :valkey" clauses, so keys that collide with DynamoDB reserved
+	// words (e.g. "status") are routed through ExpressionAttributeNames
+	// automatically, and values keep their native numeric/bool/list type
+	// instead of being stringified.
+	update := expression.Set(expression.Name("processing_status"), expression.Value(status)).
+		Set(expression.Name("last_updated"), expression.Value(time.Now().UTC().Format(time.RFC3339)))
+	for key, value := range additionalData {
+		update = update.Set(expression.Name(key), expression.Value(value))
+	}
+
+	expr, err := expression.NewBuilder().WithUpdate(update).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build update expression: %w", err)
+	}
+
+	_, err = dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]dynamodbTypes.AttributeValue{
+			"file_id":          &dynamodbTypes.AttributeValueMemberS{Value: fileID},
+			"upload_timestamp": &dynamodbTypes.AttributeValueMemberS{Value: uploadTimestamp},
+		},
+		UpdateExpression:          expr.Update(),
+		ExpressionAttributeNames:  expr.Names(),
+		ExpressionAttributeValues: expr.Values(),
+	})
+
+	if err != nil {
+		logger.Log(ERROR, "ERROR", "Failed to update file status", map[string]interface{}{
+			"fileId": fileID,
+			"status": status,
+			"error":  err.Error(),
+		})
+		return err
+	}
+
+	logger.Log(DEBUG, "DEBUG", "DynamoDB status updated", map[string]interface{}{
+		"fileId": fileID,
+		"status": status,
+	})
+	return nil
+}
+
+// recordStageCheckpoint writes a per-stage checkpoint into the results row's
+// stage_updates map so the results-list Lambda can synthesize a progress bar
+// for in-flight jobs instead of only seeing "processing". The results row may
+// not exist yet the first time a stage starts, so this upserts it.
+func recordStageCheckpoint(ctx context.Context, fileID, stage string, completed bool) {
+	resultsTable := strings.Replace(os.Getenv("DYNAMODB_TABLE"), "-file-metadata", "-processing-results", 1)
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	updateExpr := "SET stage_updates.#stage.started_at = if_not_exists(stage_updates.#stage.started_at, :now)"
+	exprAttrValues := map[string]dynamodbTypes.AttributeValue{
+		":now": &dynamodbTypes.AttributeValueMemberS{Value: now},
+	}
+	if completed {
+		updateExpr += ", stage_updates.#stage.completed_at = :now"
+	}
+
+	_, err := dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(resultsTable),
+		Key: map[string]dynamodbTypes.AttributeValue{
+			"file_id": &dynamodbTypes.AttributeValueMemberS{Value: fileID},
+		},
+		UpdateExpression: aws.String("SET stage_updates = if_not_exists(stage_updates, :emptyMap)"),
+		ExpressionAttributeValues: map[string]dynamodbTypes.AttributeValue{
+			":emptyMap": &dynamodbTypes.AttributeValueMemberM{Value: map[string]dynamodbTypes.AttributeValue{}},
+		},
+	})
+	if err != nil {
+		logger.Log(WARN, "WARN", "Failed to initialize stage_updates map", map[string]interface{}{"fileId": fileID, "error": err.Error()})
+		return
+	}
+
+	_, err = dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(resultsTable),
+		Key: map[string]dynamodbTypes.AttributeValue{
+			"file_id": &dynamodbTypes.AttributeValueMemberS{Value: fileID},
+		},
+		UpdateExpression:         aws.String("SET stage_updates.#stage = if_not_exists(stage_updates.#stage, :empty)"),
+		ExpressionAttributeNames: map[string]string{"#stage": stage},
+		ExpressionAttributeValues: map[string]dynamodbTypes.AttributeValue{
+			":empty": &dynamodbTypes.AttributeValueMemberM{Value: map[string]dynamodbTypes.AttributeValue{}},
+		},
+	})
+	if err != nil {
+		logger.Log(WARN, "WARN", "Failed to initialize stage checkpoint", map[string]interface{}{"fileId": fileID, "stage": stage, "error": err.Error()})
+		return
+	}
+
+	_, err = dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(resultsTable),
+		Key: map[string]dynamodbTypes.AttributeValue{
+			"file_id": &dynamodbTypes.AttributeValueMemberS{Value: fileID},
+		},
+		UpdateExpression:          aws.String(updateExpr),
+		ExpressionAttributeNames:  map[string]string{"#stage": stage},
+		ExpressionAttributeValues: exprAttrValues,
+	})
+	if err != nil {
+		logger.Log(WARN, "WARN", "Failed to record stage checkpoint", map[string]interface{}{"fileId": fileID, "stage": stage, "error": err.Error()})
+	}
+}
+
+// updateProcessingProgress records how far a streaming Textract pagination
+// has gotten so far, so file_reader can surface incremental progress for
+// large documents instead of only "queued"/"textract" checkpoint booleans.
+func updateProcessingProgress(ctx context.Context, fileID string, pagesProcessed, linesExtracted int) {
+	resultsTable := strings.Replace(os.Getenv("DYNAMODB_TABLE"), "-file-metadata", "-processing-results", 1)
+
+	_, err := dynamoClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(resultsTable),
+		Key: map[string]dynamodbTypes.AttributeValue{
+			"file_id": &dynamodbTypes.AttributeValueMemberS{Value: fileID},
+		},
+		UpdateExpression: aws.String("SET processing_progress = :progress"),
+		ExpressionAttributeValues: map[string]dynamodbTypes.AttributeValue{
+			":progress": &dynamodbTypes.AttributeValueMemberM{Value: map[string]dynamodbTypes.AttributeValue{
+				"pages_processed": &dynamodbTypes.AttributeValueMemberN{Value: fmt.Sprintf("%d", pagesProcessed)},
+				"lines_extracted": &dynamodbTypes.AttributeValueMemberN{Value: fmt.Sprintf("%d", linesExtracted)},
+				"updated_at":      &dynamodbTypes.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339)},
+			}},
+		},
+	})
+	if err != nil {
+		logger.Log(WARN, "WARN", "Failed to update processing progress", map[string]interface{}{"fileId": fileID, "error": err.Error()})
+	}
+}
+
+// commitProcessingOutcome combines the metadata-table status update and the
+// results-table put into a single TransactWriteItems call. Previously these
+// were two independent writes (updateFileStatus then storeProcessingResults);
+// a crash between them could leave the results row populated while the
+// status row was still "processing", or vice versa. The metadata write is
+// conditioned on the row not already having reached this status, which also
+// protects against two concurrent Batch attempts both committing the same
+// terminal outcome. The results write carries no condition of its own - a
+// manual retry (chunk4-1) or an automatic backoff retry (chunk4-5) both
+// reprocess a file that already has a results row, and that newer outcome
+// must overwrite it rather than being silently dropped by an
+// attribute_not_exists(file_id) guard left over from before retries existed.
+func commitProcessingOutcome(ctx context.Context, tableName, fileID, status string, additionalData, results map[string]interface{}) error {
+	uploadTimestamp, err := resolveUploadTimestamp(ctx, tableName, fileID)
+	if err != nil {
+		return err
+	}
+
+	update := expression.Set(expression.Name("processing_status"), expression.Value(status)).
+		Set(expression.Name("last_updated"), expression.Value(time.Now().UTC().Format(time.RFC3339)))
+	for key, value := range additionalData {
+		update = update.Set(expression.Name(key), expression.Value(value))
+	}
+	metadataCondition := expression.AttributeExists(expression.Name("file_id")).
+		And(expression.Name("processing_status").NotEqual(expression.Value(status)))
+
+	metadataExpr, err := expression.NewBuilder().WithUpdate(update).WithCondition(metadataCondition).Build()
+	if err != nil {
+		return fmt.Errorf("failed to build update expression: %w", err)
+	}
+
+	resultsTable := strings.Replace(tableName, "-file-metadata", "-processing-results", 1)
+	item, err := attributevalue.MarshalMap(results)
+	if err != nil {
+		return fmt.Errorf("failed to marshal processing results: %w", err)
+	}
+	item["file_id"] = &dynamodbTypes.AttributeValueMemberS{Value: fileID}
+
+	_, err = dynamoClient.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []dynamodbTypes.TransactWriteItem{
+			{
+				Update: &dynamodbTypes.Update{
+					TableName: aws.String(tableName),
+					Key: map[string]dynamodbTypes.AttributeValue{
+						"file_id":          &dynamodbTypes.AttributeValueMemberS{Value: fileID},
+						"upload_timestamp": &dynamodbTypes.AttributeValueMemberS{Value: uploadTimestamp},
+					},
+					UpdateExpression:          metadataExpr.Update(),
+					ConditionExpression:       metadataExpr.Condition(),
+					ExpressionAttributeNames:  metadataExpr.Names(),
+					ExpressionAttributeValues: metadataExpr.Values(),
+				},
+			},
+			{
+				Put: &dynamodbTypes.Put{
+					TableName: aws.String(resultsTable),
+					Item:      item,
+				},
+			},
+		},
+	})
+
+	if err != nil {
+		return commitProcessingOutcomeError(fileID, status, err)
+	}
+
+	logger.Log(DEBUG, "DEBUG", "Processing outcome committed", map[string]interface{}{
+		"fileId": fileID,
+		"status": status,
+		"table":  resultsTable,
+	})
+	return nil
+}
+
+// commitProcessingOutcomeError turns a TransactWriteItems cancellation into
+// a message that tells the caller whether the file was already processed
+// (benign - a concurrent/retried attempt lost the race) or something else
+// went wrong, instead of surfacing the opaque TransactionCanceledException.
+func commitProcessingOutcomeError(fileID, status string, err error) error {
+	var canceled *dynamodbTypes.TransactionCanceledException
+	if !errors.As(err, &canceled) {
+		logger.Log(ERROR, "ERROR", "Failed to commit processing outcome", map[string]interface{}{
+			"fileId": fileID,
+			"status": status,
+			"error":  err.Error(),
+		})
+		return err
+	}
+
+	reasons := make([]string, len(canceled.CancellationReasons))
+	for i, reason := range canceled.CancellationReasons {
+		if reason.Code != nil {
+			reasons[i] = *reason.Code
+		}
+	}
+	logger.Log(WARN, "WARN", "Processing outcome transaction canceled", map[string]interface{}{
+		"fileId":  fileID,
+		"status":  status,
+		"reasons": reasons,
+	})
+
+	if len(reasons) > 0 && reasons[0] == "ConditionalCheckFailed" {
+		return fmt.Errorf("file %s is already past status %q: %w", fileID, status, err)
+	}
+	return fmt.Errorf("processing outcome transaction canceled: %w", err)
 }
\ No newline at end of file
@@ -0,0 +1,200 @@
+package main
+
+// object_store.go decouples processS3File from *s3.Client so the same
+// binary can run against on-prem MinIO for dev/testing. Textract still
+// needs a real S3 URI regardless of which store holds the source object, so
+// when the configured store isn't S3, stageToS3 copies the object into a
+// scratch S3 bucket before StartDocumentAnalysis and cleans it up after.
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// ObjectHead is the subset of object metadata processS3File needs,
+// independent of which backend served it.
+type ObjectHead struct {
+	ContentLength int64
+	ContentType   string
+	LastModified  time.Time
+}
+
+// ObjectStore abstracts the handful of S3-shaped operations this pipeline
+// needs so it can run against either AWS S3 or a MinIO-compatible endpoint.
+type ObjectStore interface {
+	Head(ctx context.Context, bucket, key string) (ObjectHead, error)
+	Get(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+	PresignedGet(ctx context.Context, bucket, key string, expires time.Duration) (string, error)
+	Put(ctx context.Context, bucket, key string, body io.Reader) error
+}
+
+// NewObjectStore builds the ObjectStore selected by OBJECT_STORE_KIND
+// ("s3", the default, or "minio"). MinIO is configured via
+// OBJECT_STORE_ENDPOINT, MINIO_ACCESS_KEY, MINIO_SECRET_KEY, and
+// MINIO_USE_SSL.
+func NewObjectStore(cfg aws.Config) (ObjectStore, error) {
+	switch objectStoreKind() {
+	case "minio":
+		return newMinioObjectStore()
+	default:
+		client := s3.NewFromConfig(cfg)
+		return &s3ObjectStore{client: client, presign: s3.NewPresignClient(client)}, nil
+	}
+}
+
+func objectStoreKind() string {
+	kind := os.Getenv("OBJECT_STORE_KIND")
+	if kind == "" {
+		return "s3"
+	}
+	return kind
+}
+
+// s3ObjectStore is the default ObjectStore backed by AWS S3.
+type s3ObjectStore struct {
+	client  *s3.Client
+	presign *s3.PresignClient
+}
+
+func (o *s3ObjectStore) Head(ctx context.Context, bucket, key string) (ObjectHead, error) {
+	resp, err := o.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return ObjectHead{}, err
+	}
+
+	head := ObjectHead{ContentLength: aws.ToInt64(resp.ContentLength), ContentType: aws.ToString(resp.ContentType)}
+	if resp.LastModified != nil {
+		head.LastModified = *resp.LastModified
+	}
+	return head, nil
+}
+
+func (o *s3ObjectStore) Get(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	resp, err := o.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (o *s3ObjectStore) PresignedGet(ctx context.Context, bucket, key string, expires time.Duration) (string, error) {
+	req, err := o.presign.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
+
+func (o *s3ObjectStore) Put(ctx context.Context, bucket, key string, body io.Reader) error {
+	_, err := o.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   body,
+	})
+	return err
+}
+
+// minioObjectStore is the ObjectStore used for on-prem/dev setups.
+type minioObjectStore struct {
+	client *minio.Client
+}
+
+func newMinioObjectStore() (*minioObjectStore, error) {
+	endpoint := os.Getenv("OBJECT_STORE_ENDPOINT")
+	if endpoint == "" {
+		return nil, fmt.Errorf("OBJECT_STORE_ENDPOINT must be set when OBJECT_STORE_KIND=minio")
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(os.Getenv("MINIO_ACCESS_KEY"), os.Getenv("MINIO_SECRET_KEY"), ""),
+		Secure: os.Getenv("MINIO_USE_SSL") == "true",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create MinIO client: %w", err)
+	}
+
+	return &minioObjectStore{client: client}, nil
+}
+
+func (o *minioObjectStore) Head(ctx context.Context, bucket, key string) (ObjectHead, error) {
+	info, err := o.client.StatObject(ctx, bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return ObjectHead{}, err
+	}
+	return ObjectHead{ContentLength: info.Size, ContentType: info.ContentType, LastModified: info.LastModified}, nil
+}
+
+func (o *minioObjectStore) Get(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	return o.client.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+}
+
+func (o *minioObjectStore) PresignedGet(ctx context.Context, bucket, key string, expires time.Duration) (string, error) {
+	url, err := o.client.PresignedGetObject(ctx, bucket, key, expires, nil)
+	if err != nil {
+		return "", err
+	}
+	return url.String(), nil
+}
+
+func (o *minioObjectStore) Put(ctx context.Context, bucket, key string, body io.Reader) error {
+	_, err := o.client.PutObject(ctx, bucket, key, body, -1, minio.PutObjectOptions{})
+	return err
+}
+
+// stageToS3 copies an object from the configured (non-S3) ObjectStore into
+// TEXTRACT_STAGING_BUCKET so StartDocumentAnalysis has a real S3 URI to
+// read from. The returned cleanup func deletes the staged copy and should
+// be deferred by the caller.
+func stageToS3(ctx context.Context, sourceBucket, sourceKey string) (stagedBucket, stagedKey string, cleanup func(), err error) {
+	stagingBucket := os.Getenv("TEXTRACT_STAGING_BUCKET")
+	if stagingBucket == "" {
+		return "", "", func() {}, fmt.Errorf("TEXTRACT_STAGING_BUCKET must be set to stage non-S3 objects for Textract")
+	}
+
+	reader, err := objectStore.Get(ctx, sourceBucket, sourceKey)
+	if err != nil {
+		return "", "", func() {}, fmt.Errorf("failed to read source object for staging: %w", err)
+	}
+	defer reader.Close()
+
+	stagedKey = fmt.Sprintf("textract-staging/%s", sourceKey)
+	if _, err := s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(stagingBucket),
+		Key:    aws.String(stagedKey),
+		Body:   reader,
+	}); err != nil {
+		return "", "", func() {}, fmt.Errorf("failed to stage object to S3: %w", err)
+	}
+
+	cleanup = func() {
+		if _, err := s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(stagingBucket),
+			Key:    aws.String(stagedKey),
+		}); err != nil {
+			logger.Log(WARN, "WARN", "Failed to clean up staged Textract object", map[string]interface{}{
+				"bucket": stagingBucket,
+				"key":    stagedKey,
+				"error":  err.Error(),
+			})
+		}
+	}
+
+	return stagingBucket, stagedKey, cleanup, nil
+}
@@ -0,0 +1,227 @@
+package main
+
+// ocr_engine.go decouples text extraction from Textract so other OCR
+// backends can be swapped in (or used as a confidence-based fallback)
+// without touching processS3File.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OCREngine is implemented by every text-extraction backend the pipeline can
+// use. s3Uri is always an "s3://bucket/key" URI so Tesseract/PaddleOCR
+// sidecars that cannot reach S3 directly can be handed a presigned URL by the
+// caller if needed.
+type OCREngine interface {
+	Extract(ctx context.Context, s3Uri string) (*TextractResult, error)
+	Name() string
+	SupportsAsync() bool
+}
+
+// textractEngine adapts the existing StartDocumentAnalysis/poll loop to the
+// OCREngine interface.
+type textractEngine struct{}
+
+func (e *textractEngine) Name() string        { return "textract" }
+func (e *textractEngine) SupportsAsync() bool { return true }
+
+func (e *textractEngine) Extract(ctx context.Context, s3Uri string) (*TextractResult, error) {
+	bucket, key, err := parseS3URI(s3Uri)
+	if err != nil {
+		return nil, err
+	}
+	return processFileWithTextract(ctx, bucket, key)
+}
+
+// sidecarEngine implements OCREngine for HTTP OCR sidecars (Tesseract,
+// PaddleOCR) that accept {"s3Uri": "..."} and return {"text", "confidence",
+// "wordCount"}.
+type sidecarEngine struct {
+	name     string
+	endpoint string
+	client   *http.Client
+}
+
+func (e *sidecarEngine) Name() string        { return e.name }
+func (e *sidecarEngine) SupportsAsync() bool { return false }
+
+func (e *sidecarEngine) Extract(ctx context.Context, s3Uri string) (*TextractResult, error) {
+	reqBody, err := json.Marshal(map[string]string{"s3Uri": s3Uri})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s request: %w", e.name, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build %s request: %w", e.name, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	start := time.Now()
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s request failed: %w", e.name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s returned status %d", e.name, resp.StatusCode)
+	}
+
+	var parsed struct {
+		Text       string  `json:"text"`
+		Confidence float32 `json:"confidence"`
+		WordCount  int     `json:"wordCount"`
+		LineCount  int     `json:"lineCount"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("failed to decode %s response: %w", e.name, err)
+	}
+
+	return &TextractResult{
+		Text:       parsed.Text,
+		WordCount:  parsed.WordCount,
+		LineCount:  parsed.LineCount,
+		Confidence: parsed.Confidence,
+		Engine:     e.name,
+		Duration:   time.Since(start).Seconds(),
+	}, nil
+}
+
+// EngineRouter runs a primary OCR engine and, when its result looks weak
+// (low confidence or suspiciously few words), transparently retries with
+// configured fallback engines and keeps whichever result scored highest.
+type EngineRouter struct {
+	primary       OCREngine
+	fallbacks     []OCREngine
+	minConfidence float32
+	minWordCount  int
+}
+
+// NewEngineRouter builds the engine chain from OCR_PRIMARY_ENGINE,
+// OCR_FALLBACK_ENGINES (comma-separated), and OCR_MIN_CONFIDENCE.
+func NewEngineRouter() *EngineRouter {
+	engines := map[string]OCREngine{
+		"textract": &textractEngine{},
+	}
+	if endpoint := os.Getenv("TESSERACT_ENDPOINT"); endpoint != "" {
+		engines["tesseract"] = &sidecarEngine{name: "tesseract", endpoint: endpoint, client: &http.Client{Timeout: 60 * time.Second}}
+	}
+	if endpoint := os.Getenv("PADDLEOCR_ENDPOINT"); endpoint != "" {
+		engines["paddleocr"] = &sidecarEngine{name: "paddleocr", endpoint: endpoint, client: &http.Client{Timeout: 60 * time.Second}}
+	}
+
+	primaryName := os.Getenv("OCR_PRIMARY_ENGINE")
+	if primaryName == "" {
+		primaryName = "textract"
+	}
+	primary, ok := engines[primaryName]
+	if !ok {
+		logger.Log(WARN, "WARN", "Unknown OCR_PRIMARY_ENGINE, falling back to textract", map[string]interface{}{"requested": primaryName})
+		primary = engines["textract"]
+	}
+
+	var fallbacks []OCREngine
+	for _, name := range strings.Split(os.Getenv("OCR_FALLBACK_ENGINES"), ",") {
+		name = strings.TrimSpace(name)
+		if engine, ok := engines[name]; ok && engine != primary {
+			fallbacks = append(fallbacks, engine)
+		}
+	}
+
+	minConfidence := float32(80)
+	if v := os.Getenv("OCR_MIN_CONFIDENCE"); v != "" {
+		if parsed, err := strconv.ParseFloat(v, 32); err == nil {
+			minConfidence = float32(parsed)
+		}
+	}
+
+	return &EngineRouter{
+		primary:       primary,
+		fallbacks:     fallbacks,
+		minConfidence: minConfidence,
+		minWordCount:  5,
+	}
+}
+
+// Extract runs the primary engine and, if its result is below the confidence
+// threshold or has a suspiciously low word count, tries each fallback engine
+// in turn and keeps whichever result has the highest confidence.
+func (r *EngineRouter) Extract(ctx context.Context, s3Uri string) (*TextractResult, error) {
+	best, err := r.primary.Extract(ctx, s3Uri)
+	if err != nil {
+		if ctx.Err() != nil {
+			// The context is already done (e.g. a stage sub-deadline
+			// expired) - trying a fallback engine would just fail the
+			// same way, so surface the context error as-is.
+			return nil, ctx.Err()
+		}
+		logger.Log(WARN, "WARN", "Primary OCR engine failed", map[string]interface{}{"engine": r.primary.Name(), "error": err.Error()})
+		best = nil
+	}
+
+	if best != nil && best.Confidence >= r.minConfidence && best.WordCount >= r.minWordCount {
+		return best, nil
+	}
+
+	for _, fallback := range r.fallbacks {
+		logger.Log(INFO, "INFO", "Retrying extraction with fallback OCR engine", map[string]interface{}{
+			"engine":          fallback.Name(),
+			"primaryConfidence": confidenceOf(best),
+		})
+
+		result, err := fallback.Extract(ctx, s3Uri)
+		if err != nil {
+			logger.Log(WARN, "WARN", "Fallback OCR engine failed", map[string]interface{}{"engine": fallback.Name(), "error": err.Error()})
+			continue
+		}
+
+		if best == nil || result.Confidence > best.Confidence {
+			best = result
+		}
+
+		if best.Confidence >= r.minConfidence && best.WordCount >= r.minWordCount {
+			break
+		}
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("all OCR engines failed for %s", s3Uri)
+	}
+
+	return best, nil
+}
+
+func confidenceOf(result *TextractResult) float32 {
+	if result == nil {
+		return 0
+	}
+	return result.Confidence
+}
+
+func parseS3URI(s3Uri string) (bucket, key string, err error) {
+	const prefix = "s3://"
+	if !strings.HasPrefix(s3Uri, prefix) {
+		return "", "", fmt.Errorf("invalid S3 URI: %s", s3Uri)
+	}
+
+	trimmed := strings.TrimPrefix(s3Uri, prefix)
+	parts := strings.SplitN(trimmed, "/", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("invalid S3 URI: %s", s3Uri)
+	}
+
+	return parts[0], parts[1], nil
+}
+
+// ocrRouter is configured once in initializeAWSClients and used by
+// processS3File for every file processed by this container.
+var ocrRouter *EngineRouter
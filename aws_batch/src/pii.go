@@ -0,0 +1,198 @@
+package main
+
+// pii.go adds a PII detection/redaction stage to the Comprehend pipeline.
+// ContainsPiiEntities is a cheap yes/no gate over Comprehend's PII labels;
+// the more expensive DetectPiiEntities (which also returns span offsets)
+// only runs once that gate reports at least one label above the
+// confidence threshold.
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/comprehend"
+	comprehendTypes "github.com/aws/aws-sdk-go-v2/service/comprehend/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// PIIEntityResult is a single PII span DetectPiiEntities found, enriched
+// with the substring its offsets cover (DetectPiiEntities itself only
+// returns Type/Score/offsets, not the matched text).
+type PIIEntityResult struct {
+	Text        string  `json:"Text" dynamodbav:"Text"`
+	Type        string  `json:"Type" dynamodbav:"Type"`
+	Score       float32 `json:"Score" dynamodbav:"Score"`
+	BeginOffset int32   `json:"BeginOffset" dynamodbav:"BeginOffset"`
+	EndOffset   int32   `json:"EndOffset" dynamodbav:"EndOffset"`
+}
+
+const defaultPIIConfidenceThreshold = 0.8
+
+// defaultPIIRedactionPolicy decides, per PII type, whether a redacted copy
+// of the text replaces a span outright ("redact"), masks it but keeps the
+// last 4 characters ("mask"), or only flags its presence without altering
+// the text ("annotate"). Overridden via PII_REDACTION_POLICY, formatted as
+// "TYPE:action,TYPE:action".
+var defaultPIIRedactionPolicy = map[string]string{
+	"SSN":                 "redact",
+	"CREDIT_DEBIT_NUMBER": "redact",
+	"EMAIL":               "mask",
+	"PHONE":               "mask",
+	"ADDRESS":             "annotate",
+}
+
+func piiConfidenceThreshold() float32 {
+	threshold := float32(defaultPIIConfidenceThreshold)
+	if v := os.Getenv("PII_MIN_CONFIDENCE"); v != "" {
+		if parsed, err := strconv.ParseFloat(v, 32); err == nil {
+			threshold = float32(parsed)
+		}
+	}
+	return threshold
+}
+
+// piiRedactionPolicy returns defaultPIIRedactionPolicy overlaid with any
+// per-type overrides from PII_REDACTION_POLICY.
+func piiRedactionPolicy() map[string]string {
+	policy := make(map[string]string, len(defaultPIIRedactionPolicy))
+	for piiType, action := range defaultPIIRedactionPolicy {
+		policy[piiType] = action
+	}
+
+	raw := os.Getenv("PII_REDACTION_POLICY")
+	if raw == "" {
+		return policy
+	}
+	for _, pair := range strings.Split(raw, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		policy[strings.ToUpper(strings.TrimSpace(parts[0]))] = strings.ToLower(strings.TrimSpace(parts[1]))
+	}
+	return policy
+}
+
+func piiStrictModeEnabled() bool {
+	return strings.EqualFold(os.Getenv("PII_STRICT_MODE"), "true")
+}
+
+// detectPII runs the ContainsPiiEntities gate first, and only calls the
+// more expensive DetectPiiEntities when the gate reports at least one
+// label above the confidence threshold.
+func detectPII(ctx context.Context, text, langCode string) ([]PIIEntityResult, bool, error) {
+	threshold := piiConfidenceThreshold()
+
+	gateResp, err := comprehendClient.ContainsPiiEntities(ctx, &comprehend.ContainsPiiEntitiesInput{
+		Text:         aws.String(text),
+		LanguageCode: comprehendTypes.LanguageCode(langCode),
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("ContainsPiiEntities failed: %w", err)
+	}
+
+	found := false
+	for _, label := range gateResp.Labels {
+		if aws.ToFloat32(label.Score) >= threshold {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, false, nil
+	}
+
+	detectResp, err := comprehendClient.DetectPiiEntities(ctx, &comprehend.DetectPiiEntitiesInput{
+		Text:         aws.String(text),
+		LanguageCode: comprehendTypes.LanguageCode(langCode),
+	})
+	if err != nil {
+		return nil, true, fmt.Errorf("DetectPiiEntities failed: %w", err)
+	}
+
+	var entities []PIIEntityResult
+	for _, entity := range detectResp.Entities {
+		if aws.ToFloat32(entity.Score) < threshold {
+			continue
+		}
+		begin, end := aws.ToInt32(entity.BeginOffset), aws.ToInt32(entity.EndOffset)
+		if begin < 0 || end > int32(len(text)) || begin >= end {
+			continue
+		}
+		entities = append(entities, PIIEntityResult{
+			Text:        text[begin:end],
+			Type:        string(entity.Type),
+			Score:       aws.ToFloat32(entity.Score),
+			BeginOffset: begin,
+			EndOffset:   end,
+		})
+	}
+
+	return entities, true, nil
+}
+
+// redactPII produces a redacted copy of text by applying each entity's
+// configured policy action. Spans are walked in descending BeginOffset
+// order so replacing a later (higher-offset) span never invalidates the
+// offsets of the ones still to come.
+func redactPII(text string, entities []PIIEntityResult, policy map[string]string) string {
+	ordered := make([]PIIEntityResult, len(entities))
+	copy(ordered, entities)
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].BeginOffset > ordered[j].BeginOffset })
+
+	redacted := text
+	for _, entity := range ordered {
+		action := policy[entity.Type]
+
+		var replacement string
+		switch action {
+		case "redact":
+			replacement = fmt.Sprintf("[REDACTED-%s]", entity.Type)
+		case "mask":
+			replacement = maskKeepLast4(entity.Text)
+		default: // "annotate", or any unconfigured type: leave the span untouched
+			continue
+		}
+
+		redacted = redacted[:entity.BeginOffset] + replacement + redacted[entity.EndOffset:]
+	}
+
+	return redacted
+}
+
+// maskKeepLast4 replaces every character but the trailing 4 with "*". A
+// value of 4 or fewer characters is masked entirely.
+func maskKeepLast4(value string) string {
+	runes := []rune(value)
+	if len(runes) <= 4 {
+		return strings.Repeat("*", len(runes))
+	}
+	return strings.Repeat("*", len(runes)-4) + string(runes[len(runes)-4:])
+}
+
+// persistRedactedText uploads the redacted copy of the OCR text to its own
+// S3 key, separate from the source object, defaulting to the source
+// bucket when REDACTED_TEXT_S3_BUCKET isn't set. It returns the resulting
+// S3 URI.
+func persistRedactedText(ctx context.Context, sourceBucket, fileID, redactedText string) (string, error) {
+	bucket := os.Getenv("REDACTED_TEXT_S3_BUCKET")
+	if bucket == "" {
+		bucket = sourceBucket
+	}
+	key := fmt.Sprintf("redacted-text/%s.txt", fileID)
+
+	if _, err := s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   strings.NewReader(redactedText),
+	}); err != nil {
+		return "", fmt.Errorf("failed to upload redacted text: %w", err)
+	}
+
+	return fmt.Sprintf("s3://%s/%s", bucket, key), nil
+}
@@ -0,0 +1,237 @@
+package main
+
+// textract_wait.go lets processFileWithTextract avoid sleeping in a fixed
+// 5-second poll loop: when TEXTRACT_SNS_TOPIC_ARN/TEXTRACT_SNS_ROLE_ARN are
+// configured, Textract publishes a completion notification to SNS, which we
+// fan out to a per-job ephemeral SQS queue and block on with long polling.
+// The old poll loop remains as a fallback when those env vars are unset.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqsTypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/aws-sdk-go-v2/service/textract"
+	textractTypes "github.com/aws/aws-sdk-go-v2/service/textract/types"
+)
+
+// textractNotification mirrors the JSON payload Textract publishes to SNS on
+// job completion.
+type textractNotification struct {
+	JobID   string `json:"JobId"`
+	Status  string `json:"Status"`
+	API     string `json:"API"`
+	Message string `json:"StatusMessage"`
+}
+
+// snsNotificationEnabled reports whether both SNS env vars required for the
+// notification-channel wait path are configured.
+func snsNotificationEnabled() bool {
+	return os.Getenv("TEXTRACT_SNS_TOPIC_ARN") != "" && os.Getenv("TEXTRACT_SNS_ROLE_ARN") != ""
+}
+
+// waitForTextractCompletion blocks until the given Textract job finishes,
+// preferring the SNS/SQS notification path when configured and falling back
+// to polling GetDocumentAnalysis otherwise. It logs how long the wait took
+// and which mode was used so the two paths can be compared.
+func waitForTextractCompletion(ctx context.Context, jobID string) error {
+	waitStart := time.Now()
+	mode := "poll"
+
+	var err error
+	if snsNotificationEnabled() {
+		mode = "sns"
+		err = waitForJobViaSNS(ctx, jobID)
+	} else {
+		err = pollForJobCompletion(ctx, jobID)
+	}
+
+	logger.Log(INFO, "INFO", "Textract job wait completed", map[string]interface{}{
+		"textractJobId": jobID,
+		"waitMode":      mode,
+		"waitSeconds":   time.Since(waitStart).Seconds(),
+		"succeeded":     err == nil,
+	})
+
+	return err
+}
+
+// pollForJobCompletion is the original fixed-interval poll loop.
+func pollForJobCompletion(ctx context.Context, jobID string) error {
+	maxAttempts := 60
+	for attempts := 0; attempts < maxAttempts; attempts++ {
+		time.Sleep(5 * time.Second)
+
+		statusResp, err := textractClient.GetDocumentAnalysis(ctx, &textract.GetDocumentAnalysisInput{
+			JobId: aws.String(jobID),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get Textract status: %w", err)
+		}
+
+		jobStatus := statusResp.JobStatus
+		if attempts%6 == 0 {
+			logger.Log(INFO, "INFO", "Waiting for Textract completion", map[string]interface{}{
+				"status":      jobStatus,
+				"attempt":     attempts,
+				"maxAttempts": maxAttempts,
+			})
+		}
+
+		if jobStatus == textractTypes.JobStatusFailed {
+			return fmt.Errorf("Textract job failed: %s", aws.ToString(statusResp.StatusMessage))
+		}
+
+		if jobStatus == textractTypes.JobStatusSucceeded {
+			return nil
+		}
+
+		if attempts == maxAttempts-1 {
+			return fmt.Errorf("Textract job timeout after %d attempts", maxAttempts)
+		}
+	}
+
+	return fmt.Errorf("Textract job timeout after %d attempts", maxAttempts)
+}
+
+// waitForJobViaSNS creates an ephemeral SQS queue subscribed to the
+// Textract SNS topic, then long-polls it until a notification for jobID
+// arrives. The queue and subscription are torn down before returning.
+func waitForJobViaSNS(ctx context.Context, jobID string) error {
+	queueName := fmt.Sprintf("textract-notify-%s", jobID)
+
+	createResp, err := sqsClient.CreateQueue(ctx, &sqs.CreateQueueInput{
+		QueueName: aws.String(queueName),
+		Attributes: map[string]string{
+			"MessageRetentionPeriod": "3600",
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create notification queue: %w", err)
+	}
+	queueURL := aws.ToString(createResp.QueueUrl)
+	defer cleanupNotificationQueue(ctx, queueURL)
+
+	attrsResp, err := sqsClient.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       aws.String(queueURL),
+		AttributeNames: []sqsTypes.QueueAttributeName{sqsTypes.QueueAttributeNameQueueArn},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to resolve notification queue ARN: %w", err)
+	}
+	queueArn := attrsResp.Attributes["QueueArn"]
+
+	if err := allowSNSToSendToQueue(ctx, queueURL, queueArn); err != nil {
+		return fmt.Errorf("failed to authorize SNS on notification queue: %w", err)
+	}
+
+	subscribeResp, err := snsClient.Subscribe(ctx, &sns.SubscribeInput{
+		TopicArn: aws.String(os.Getenv("TEXTRACT_SNS_TOPIC_ARN")),
+		Protocol: aws.String("sqs"),
+		Endpoint: aws.String(queueArn),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe notification queue to SNS topic: %w", err)
+	}
+	subscriptionArn := aws.ToString(subscribeResp.SubscriptionArn)
+	defer unsubscribeNotificationQueue(ctx, subscriptionArn)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		receiveResp, err := sqsClient.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:            aws.String(queueURL),
+			MaxNumberOfMessages: 1,
+			WaitTimeSeconds:     20,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to receive Textract notification: %w", err)
+		}
+
+		for _, message := range receiveResp.Messages {
+			notification, ok := parseTextractNotification(aws.ToString(message.Body))
+			if ok && notification.JobID == jobID {
+				sqsClient.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+					QueueUrl:      aws.String(queueURL),
+					ReceiptHandle: message.ReceiptHandle,
+				})
+
+				if notification.Status == string(textractTypes.JobStatusFailed) {
+					return fmt.Errorf("Textract job failed: %s", notification.Message)
+				}
+				return nil
+			}
+
+			sqsClient.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+				QueueUrl:      aws.String(queueURL),
+				ReceiptHandle: message.ReceiptHandle,
+			})
+		}
+	}
+}
+
+// parseTextractNotification unwraps the SNS envelope (a "Message" field
+// containing the actual Textract JSON payload as a string) that arrives on
+// SQS when a message is delivered via an SNS subscription.
+func parseTextractNotification(body string) (textractNotification, bool) {
+	var envelope struct {
+		Message string `json:"Message"`
+	}
+	if err := json.Unmarshal([]byte(body), &envelope); err != nil || envelope.Message == "" {
+		return textractNotification{}, false
+	}
+
+	var notification textractNotification
+	if err := json.Unmarshal([]byte(envelope.Message), &notification); err != nil {
+		return textractNotification{}, false
+	}
+
+	return notification, notification.JobID != ""
+}
+
+// allowSNSToSendToQueue grants the configured SNS topic permission to
+// publish to the ephemeral queue via SetQueueAttributes policy.
+func allowSNSToSendToQueue(ctx context.Context, queueURL, queueArn string) error {
+	topicArn := os.Getenv("TEXTRACT_SNS_TOPIC_ARN")
+	policy := fmt.Sprintf(`{
+		"Version": "2012-10-17",
+		"Statement": [{
+			"Effect": "Allow",
+			"Principal": {"Service": "sns.amazonaws.com"},
+			"Action": "sqs:SendMessage",
+			"Resource": "%s",
+			"Condition": {"ArnEquals": {"aws:SourceArn": "%s"}}
+		}]
+	}`, queueArn, topicArn)
+
+	_, err := sqsClient.SetQueueAttributes(ctx, &sqs.SetQueueAttributesInput{
+		QueueUrl: aws.String(queueURL),
+		Attributes: map[string]string{
+			"Policy": strings.Join(strings.Fields(policy), " "),
+		},
+	})
+	return err
+}
+
+func cleanupNotificationQueue(ctx context.Context, queueURL string) {
+	if _, err := sqsClient.DeleteQueue(ctx, &sqs.DeleteQueueInput{QueueUrl: aws.String(queueURL)}); err != nil {
+		logger.Log(WARN, "WARN", "Failed to delete ephemeral notification queue", map[string]interface{}{"queueUrl": queueURL, "error": err.Error()})
+	}
+}
+
+func unsubscribeNotificationQueue(ctx context.Context, subscriptionArn string) {
+	if _, err := snsClient.Unsubscribe(ctx, &sns.UnsubscribeInput{SubscriptionArn: aws.String(subscriptionArn)}); err != nil {
+		logger.Log(WARN, "WARN", "Failed to unsubscribe notification queue", map[string]interface{}{"subscriptionArn": subscriptionArn, "error": err.Error()})
+	}
+}
@@ -13,6 +13,10 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/batch"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"github.com/CaringalML/OCR-Processing-Pipeline-Using-AWS-Batch-Serverless-Architecture/pkg/batchreport"
+	"github.com/CaringalML/OCR-Processing-Pipeline-Using-AWS-Batch-Serverless-Architecture/pkg/blobstore"
 )
 
 // EventBridge Batch job state change event structure
@@ -33,10 +37,19 @@ type Response struct {
 	Body       string `json:"body"`
 }
 
+// DynamoAPI is the subset of *dynamodb.DynamoDB this Lambda calls. It lets
+// tests substitute an in-memory table (see internal/awsfake) without
+// pulling in the full dynamodbiface.DynamoDBAPI surface for two methods.
+type DynamoAPI interface {
+	UpdateItem(*dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error)
+	Query(*dynamodb.QueryInput) (*dynamodb.QueryOutput, error)
+}
+
 var (
 	batchClient  *batch.Batch
-	dynamoClient *dynamodb.DynamoDB
+	dynamoClient DynamoAPI
 	dynamoTable  string
+	reportStore  blobstore.Store
 )
 
 func init() {
@@ -45,6 +58,14 @@ func init() {
 	dynamoClient = dynamodb.New(sess)
 
 	dynamoTable = os.Getenv("DYNAMODB_TABLE")
+
+	if reportsBucket := os.Getenv("REPORTS_BUCKET_NAME"); reportsBucket != "" {
+		var err error
+		reportStore, err = blobstore.New(reportsBucket)
+		if err != nil {
+			log.Fatalf("failed to build report store: %v", err)
+		}
+	}
 }
 
 func main() {
@@ -112,6 +133,8 @@ func handleRequest(ctx context.Context, event EventBridgeEvent) (Response, error
 		}, nil
 	}
 
+	writeJobReport(fileID, jobID, jobName, jobStatus, detail)
+
 	log.Printf("Successfully updated status for file_id: %s", fileID)
 	return Response{
 		StatusCode: 200,
@@ -244,4 +267,82 @@ func getUploadTimestamp(fileID string) (string, error) {
 	}
 
 	return *uploadTimestamp.S, nil
+}
+
+type fileLocation struct {
+	S3Key string `dynamodbav:"s3_key"`
+}
+
+func getFileLocation(fileID string) (fileLocation, error) {
+	queryInput := &dynamodb.QueryInput{
+		TableName:              aws.String(dynamoTable),
+		KeyConditionExpression: aws.String("file_id = :file_id"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":file_id": {S: aws.String(fileID)},
+		},
+		Limit: aws.Int64(1),
+	}
+
+	result, err := dynamoClient.Query(queryInput)
+	if err != nil {
+		return fileLocation{}, fmt.Errorf("failed to query file metadata: %v", err)
+	}
+	if len(result.Items) == 0 {
+		return fileLocation{}, fmt.Errorf("file metadata not found for file_id: %s", fileID)
+	}
+
+	var loc fileLocation
+	if err := dynamodbattribute.UnmarshalMap(result.Items[0], &loc); err != nil {
+		return fileLocation{}, fmt.Errorf("failed to unmarshal file metadata: %v", err)
+	}
+	return loc, nil
+}
+
+// writeJobReport persists a durable BatchJobReport to S3 once a job
+// reaches SUCCEEDED or FAILED, so an operator can see what a completed
+// job actually did after DynamoDB has moved on to its latest state. It is
+// best-effort and REPORTS_BUCKET_NAME is optional - a failure here never
+// fails the status update itself.
+func writeJobReport(fileID, jobID, jobName, batchStatus string, jobDetail BatchJobDetail) {
+	if reportStore == nil {
+		return
+	}
+
+	fileStatus := "failed"
+	succeeded, failed := 0, 1
+	if batchStatus == "SUCCEEDED" {
+		fileStatus, succeeded, failed = "processed", 1, 0
+	}
+
+	report := batchreport.Report{
+		JobID:                  jobID,
+		JobName:                jobName,
+		TotalNumberOfTasks:     1,
+		NumberOfTasksSucceeded: succeeded,
+		NumberOfTasksFailed:    failed,
+		FinishedAt:             time.Now().UTC().Format(time.RFC3339),
+		StatusReason:           jobDetail.StatusReason,
+	}
+
+	if loc, err := getFileLocation(fileID); err != nil {
+		log.Printf("WARNING: Failed to look up file location for report %s: %v", jobID, err)
+	} else {
+		report.Files = []batchreport.FileDetail{{FileID: fileID, S3Key: loc.S3Key, Status: fileStatus}}
+	}
+
+	if describeResult, err := batchClient.DescribeJobs(&batch.DescribeJobsInput{Jobs: []*string{aws.String(jobID)}}); err != nil {
+		log.Printf("WARNING: Failed to describe batch job %s for report: %v", jobID, err)
+	} else if len(describeResult.Jobs) > 0 {
+		job := describeResult.Jobs[0]
+		if job.StartedAt != nil {
+			report.StartedAt = time.UnixMilli(*job.StartedAt).UTC().Format(time.RFC3339)
+		}
+		if job.StoppedAt != nil {
+			report.FinishedAt = time.UnixMilli(*job.StoppedAt).UTC().Format(time.RFC3339)
+		}
+	}
+
+	if err := batchreport.Write(context.Background(), reportStore, report); err != nil {
+		log.Printf("WARNING: Failed to write batch job report for %s: %v", jobID, err)
+	}
 }
\ No newline at end of file
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
+	"github.com/CaringalML/OCR-Processing-Pipeline-Using-AWS-Batch-Serverless-Architecture/internal/awsfake"
+)
+
+func seedProcessingFile(t *testing.T, store *awsfake.DynamoStore, fileID string) {
+	t.Helper()
+	_, err := store.PutItem(&dynamodb.PutItemInput{
+		Item: map[string]*dynamodb.AttributeValue{
+			"file_id":           {S: aws.String(fileID)},
+			"upload_timestamp":  {S: aws.String("2026-07-27T00:00:00Z")},
+			"processing_status": {S: aws.String("processing")},
+		},
+	})
+	if err != nil {
+		t.Fatalf("seeding file metadata: %v", err)
+	}
+}
+
+func TestHandleRequestSucceededMarksFileProcessed(t *testing.T) {
+	store := awsfake.NewDynamoStore()
+	dynamoClient = store
+	dynamoTable = "file-metadata"
+
+	seedProcessingFile(t, store, "abc-def")
+
+	event := EventBridgeEvent{Detail: BatchJobDetail{
+		JobID:     "job-1",
+		JobName:   "process-file-abc-def-123",
+		JobStatus: "SUCCEEDED",
+	}}
+
+	resp, err := handleRequest(context.Background(), event)
+	if err != nil {
+		t.Fatalf("handleRequest returned error: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+
+	result, err := store.Query(&dynamodb.QueryInput{
+		KeyConditionExpression:    aws.String("file_id = :file_id"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":file_id": {S: aws.String("abc-def")}},
+	})
+	if err != nil || len(result.Items) != 1 {
+		t.Fatalf("expected one stored item, got %v, err=%v", result, err)
+	}
+	if got := aws.StringValue(result.Items[0]["processing_status"].S); got != "processed" {
+		t.Fatalf("expected processing_status=processed, got %q", got)
+	}
+}
+
+func TestHandleRequestSucceededIsIdempotent(t *testing.T) {
+	store := awsfake.NewDynamoStore()
+	dynamoClient = store
+	dynamoTable = "file-metadata"
+
+	seedProcessingFile(t, store, "abc-def")
+
+	event := EventBridgeEvent{Detail: BatchJobDetail{
+		JobID:     "job-1",
+		JobName:   "process-file-abc-def-123",
+		JobStatus: "SUCCEEDED",
+	}}
+
+	if _, err := handleRequest(context.Background(), event); err != nil {
+		t.Fatalf("first delivery: %v", err)
+	}
+
+	// Replaying the same event should not error just because
+	// processing_status is no longer "processing" - the guard should make
+	// this a silent no-op, matching updateStatusToProcessed's WARNING-and-
+	// return-nil behavior.
+	resp, err := handleRequest(context.Background(), event)
+	if err != nil {
+		t.Fatalf("replayed delivery returned error: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected status 200 on replay, got %d: %s", resp.StatusCode, resp.Body)
+	}
+}
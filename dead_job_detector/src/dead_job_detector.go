@@ -2,27 +2,74 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/batch"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/CaringalML/OCR-Processing-Pipeline-Using-AWS-Batch-Serverless-Architecture/pkg/batchjob"
+	"github.com/CaringalML/OCR-Processing-Pipeline-Using-AWS-Batch-Serverless-Architecture/pkg/batchreport"
+	"github.com/CaringalML/OCR-Processing-Pipeline-Using-AWS-Batch-Serverless-Architecture/pkg/blobstore"
 )
 
-// StuckJob represents a job stuck in processing status
+// processingStatusIndex is the sparse GSI (partition key processing_status,
+// sort key processing_started) findStuckProcessingJobs queries instead of
+// scanning the whole table - sparse because only rows with both attributes
+// set are projected into it. A row keeps processing_started after it leaves
+// "processing", so the query's processing_status key condition is what
+// narrows the result to files still in flight.
+const processingStatusIndex = "processing-status-index"
+
+// maxSQSDelaySeconds is the hard ceiling SendMessage enforces on
+// DelaySeconds; backoffSeconds clamps to it so a third retry (nominally
+// 16 minutes out) still schedules, just sooner than the ideal backoff.
+const maxSQSDelaySeconds = 900
+
+// StuckJob represents a job stuck in processing status. item holds the raw
+// attribute map the GSI query returned, so applyStatusUpdates can PutItem a
+// patched copy of the same row via BatchWriteItem without a second read.
 type StuckJob struct {
 	FileID            string `dynamodbav:"file_id"`
 	UploadTimestamp   string `dynamodbav:"upload_timestamp"`
 	BatchJobID        string `dynamodbav:"batch_job_id"`
 	ProcessingStarted int64  `dynamodbav:"processing_started"`
+	RetryCount        int64  `dynamodbav:"retry_count"`
+	FailureHistory    string `dynamodbav:"failure_history"`
+	item              map[string]*dynamodb.AttributeValue
+}
+
+// failureAttempt is one entry of a StuckJob's FailureHistory, recording
+// enough about a single failed Batch attempt that an operator can diagnose
+// a permanently failed file from the DLQ record alone, without having to
+// cross-reference Batch or CloudWatch separately.
+type failureAttempt struct {
+	BatchJobID    string `json:"batchJobId"`
+	StatusReason  string `json:"statusReason"`
+	LogStreamLink string `json:"logStreamLink,omitempty"`
+	FailedAt      int64  `json:"failedAt"`
+}
+
+// retryMessage is the JSON body enqueued to RETRY_QUEUE_URL. It carries
+// just enough for resubmitRetry to recreate the Batch job after the
+// SQS-delivered delay elapses.
+type retryMessage struct {
+	FileID     string `json:"fileId"`
+	BucketName string `json:"bucketName"`
+	ObjectKey  string `json:"objectKey"`
 }
 
 // ProcessingResult represents the result of processing a stuck job
@@ -40,18 +87,31 @@ type Response struct {
 }
 
 var (
-	batchClient         *batch.Batch
-	dynamoClient        *dynamodb.DynamoDB
-	dynamoTable         string
+	batchClient          *batch.Batch
+	dynamoClient         *dynamodb.DynamoDB
+	sqsClient            *sqs.SQS
+	dynamoTable          string
+	jobQueue             string
+	jobDefinition        string
 	maxProcessingMinutes int64
+	reportStore          blobstore.Store
+	retryQueueURL        string
+	dlqTable             string
+	maxRetries           int64
 )
 
 func init() {
 	sess := session.Must(session.NewSession())
 	batchClient = batch.New(sess)
 	dynamoClient = dynamodb.New(sess)
+	sqsClient = sqs.New(sess)
 
 	dynamoTable = os.Getenv("DYNAMODB_TABLE")
+	jobQueue = os.Getenv("BATCH_JOB_QUEUE")
+	jobDefinition = os.Getenv("BATCH_JOB_DEFINITION")
+	retryQueueURL = os.Getenv("RETRY_QUEUE_URL")
+	dlqTable = os.Getenv("DLQ_TABLE")
+
 	maxProcessingMinutesStr := os.Getenv("MAX_PROCESSING_MINUTES")
 	if maxProcessingMinutesStr == "" {
 		maxProcessingMinutes = 120 // Default 2 hours
@@ -62,13 +122,106 @@ func init() {
 			maxProcessingMinutes = 120
 		}
 	}
+
+	maxRetriesStr := os.Getenv("MAX_RETRIES")
+	if maxRetriesStr == "" {
+		maxRetries = 3
+	} else {
+		var err error
+		maxRetries, err = strconv.ParseInt(maxRetriesStr, 10, 64)
+		if err != nil {
+			maxRetries = 3
+		}
+	}
+
+	if reportsBucket := os.Getenv("REPORTS_BUCKET_NAME"); reportsBucket != "" {
+		var err error
+		reportStore, err = blobstore.New(reportsBucket)
+		if err != nil {
+			log.Fatalf("failed to build report store: %v", err)
+		}
+	}
 }
 
 func main() {
 	lambda.Start(handleRequest)
 }
 
-func handleRequest(ctx context.Context, event interface{}) (Response, error) {
+// handleRequest serves three triggers on the same function: a scheduled
+// EventBridge rule that kicks off the dead-job scan below, the
+// operator-facing job-control API added in routeJobControl, and the retry
+// queue a stuck FAILED job is rescheduled onto by processStuckJob. All
+// three arrive as interface{} since the event shapes are unrelated;
+// decodeAPIGatewayRequest/decodeSQSEvent tell them apart before anything
+// downstream sees a typed event.
+func handleRequest(ctx context.Context, event interface{}) (interface{}, error) {
+	if request, ok := decodeAPIGatewayRequest(event); ok {
+		return routeJobControl(ctx, request)
+	}
+	if sqsEvent, ok := decodeSQSEvent(event); ok {
+		return handleRetryMessages(sqsEvent)
+	}
+	return runDeadJobScan(ctx)
+}
+
+// decodeAPIGatewayRequest reports whether event is an API Gateway proxy
+// request (identified by the presence of an "httpMethod" field) and, if
+// so, decodes it into the typed event. lambda.Start hands an interface{}
+// handler a map[string]interface{} for any JSON event, so this is a
+// round-trip through encoding/json rather than a type assertion.
+func decodeAPIGatewayRequest(event interface{}) (events.APIGatewayProxyRequest, bool) {
+	raw, ok := event.(map[string]interface{})
+	if !ok {
+		return events.APIGatewayProxyRequest{}, false
+	}
+	if _, ok := raw["httpMethod"]; !ok {
+		return events.APIGatewayProxyRequest{}, false
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return events.APIGatewayProxyRequest{}, false
+	}
+	var request events.APIGatewayProxyRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		return events.APIGatewayProxyRequest{}, false
+	}
+	return request, true
+}
+
+// decodeSQSEvent reports whether event is an SQS-triggered event (the
+// retry queue a FAILED job gets rescheduled onto) and, if so, decodes it
+// into the typed event. It is detected and decoded the same way
+// decodeAPIGatewayRequest handles API Gateway: round-trip the raw map
+// through encoding/json into the typed shape and check for the field only
+// that trigger sets.
+func decodeSQSEvent(event interface{}) (events.SQSEvent, bool) {
+	raw, ok := event.(map[string]interface{})
+	if !ok {
+		return events.SQSEvent{}, false
+	}
+	if _, ok := raw["Records"]; !ok {
+		return events.SQSEvent{}, false
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return events.SQSEvent{}, false
+	}
+	var sqsEvent events.SQSEvent
+	if err := json.Unmarshal(data, &sqsEvent); err != nil {
+		return events.SQSEvent{}, false
+	}
+	if len(sqsEvent.Records) == 0 || sqsEvent.Records[0].EventSource != "aws:sqs" {
+		return events.SQSEvent{}, false
+	}
+	return sqsEvent, true
+}
+
+// runDeadJobScan is the original scheduled behavior: find files stuck in
+// "processing" longer than MAX_PROCESSING_MINUTES and reconcile their
+// status against the real Batch job.
+func runDeadJobScan(ctx context.Context) (Response, error) {
 	log.Printf("Starting dead job detection")
 
 	// Validate environment variables
@@ -106,11 +259,36 @@ func handleRequest(ctx context.Context, event interface{}) (Response, error) {
 
 	log.Printf("Found %d stuck jobs", len(stuckJobs))
 
-	// Process each stuck job
-	var results []ProcessingResult
-	for _, job := range stuckJobs {
-		result := processStuckJob(job)
-		results = append(results, result)
+	// Decide an outcome for each stuck job first, then apply every decided
+	// DynamoDB write in one BatchWriteItem fan-out instead of one UpdateItem
+	// per job - a scan turning up thousands of stuck jobs no longer means
+	// thousands of sequential round trips.
+	results := make([]ProcessingResult, len(stuckJobs))
+	var updates []pendingStatusUpdate
+	updateIndex := make(map[string]int, len(stuckJobs))
+	for i, job := range stuckJobs {
+		result, update := processStuckJob(job)
+		results[i] = result
+		if update != nil {
+			updateIndex[update.job.FileID] = i
+			updates = append(updates, *update)
+		}
+	}
+
+	if len(updates) > 0 {
+		failed := applyStatusUpdates(updates)
+		for _, update := range updates {
+			i := updateIndex[update.job.FileID]
+			if failed[update.job.FileID] {
+				results[i] = ProcessingResult{FileID: update.job.FileID, Action: "error", Reason: "batch_write_failed", Success: false}
+				continue
+			}
+			// A "retrying" update isn't resolved yet - resubmitRetry reports
+			// the eventual outcome once the retry queue delivers it.
+			if update.status != "retrying" {
+				writeStuckJobReport(update.job, update.status, update.statusReason, update.batchJob)
+			}
+		}
 	}
 
 	successCount := 0
@@ -133,39 +311,69 @@ func handleRequest(ctx context.Context, event interface{}) (Response, error) {
 	}, nil
 }
 
+// findStuckProcessingJobs queries processingStatusIndex for every file
+// still "processing" older than maxProcessingMinutes, paginating over
+// LastEvaluatedKey until the whole index has been walked. This replaces a
+// full-table Scan, which read every item regardless of status on every
+// invocation and would stop scaling once the table held more than a few
+// thousand files.
 func findStuckProcessingJobs() ([]StuckJob, error) {
-	// Calculate cutoff time
 	cutoffTime := time.Now().Unix() - (maxProcessingMinutes * 60)
 
-	scanInput := &dynamodb.ScanInput{
-		TableName:        aws.String(dynamoTable),
-		FilterExpression: aws.String("processing_status = :status AND processing_started < :cutoff"),
-		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":status": {S: aws.String("processing")},
-			":cutoff": {N: aws.String(fmt.Sprintf("%d", cutoffTime))},
-		},
-	}
+	var stuckJobs []StuckJob
+	var exclusiveStartKey map[string]*dynamodb.AttributeValue
+	for {
+		queryInput := &dynamodb.QueryInput{
+			TableName:              aws.String(dynamoTable),
+			IndexName:              aws.String(processingStatusIndex),
+			KeyConditionExpression: aws.String("processing_status = :status AND processing_started < :cutoff"),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":status": {S: aws.String("processing")},
+				":cutoff": {N: aws.String(fmt.Sprintf("%d", cutoffTime))},
+			},
+			ExclusiveStartKey: exclusiveStartKey,
+		}
 
-	result, err := dynamoClient.Scan(scanInput)
-	if err != nil {
-		return nil, fmt.Errorf("failed to scan for stuck jobs: %v", err)
-	}
+		result, err := dynamoClient.Query(queryInput)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query stuck jobs: %v", err)
+		}
 
-	var stuckJobs []StuckJob
-	for _, item := range result.Items {
-		var job StuckJob
-		if err := dynamodbattribute.UnmarshalMap(item, &job); err != nil {
-			log.Printf("Failed to unmarshal stuck job: %v", err)
-			continue
+		for _, item := range result.Items {
+			var job StuckJob
+			if err := dynamodbattribute.UnmarshalMap(item, &job); err != nil {
+				log.Printf("Failed to unmarshal stuck job: %v", err)
+				continue
+			}
+			job.item = item
+			stuckJobs = append(stuckJobs, job)
 		}
-		stuckJobs = append(stuckJobs, job)
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		exclusiveStartKey = result.LastEvaluatedKey
 	}
 
 	log.Printf("Found %d jobs stuck in processing status", len(stuckJobs))
 	return stuckJobs, nil
 }
 
-func processStuckJob(job StuckJob) ProcessingResult {
+// pendingStatusUpdate is processStuckJob's decision for one file, deferred
+// rather than written immediately so runDeadJobScan can fan every decided
+// job's write out through a single applyStatusUpdates call instead of one
+// UpdateItem per job.
+type pendingStatusUpdate struct {
+	job            StuckJob
+	status         string // "processed", "failed", or "retrying"
+	statusReason   string
+	batchJob       *batch.JobDetail
+	retryCount     int64  // set when status is "retrying"
+	nextRetryAt    int64  // set when status is "retrying"
+	failureHistory string // set when status is "retrying" or "failed"
+}
+
+func processStuckJob(job StuckJob) (ProcessingResult, *pendingStatusUpdate) {
 	fileID := job.FileID
 	batchJobID := job.BatchJobID
 
@@ -174,20 +382,8 @@ func processStuckJob(job StuckJob) ProcessingResult {
 	// If no batch job ID, mark as failed
 	if batchJobID == "" {
 		log.Printf("WARNING: No batch_job_id for file_id %s, marking as failed", fileID)
-		if err := updateStatusToFailed(fileID, "No batch job ID found"); err != nil {
-			return ProcessingResult{
-				FileID:  fileID,
-				Action:  "error",
-				Reason:  err.Error(),
-				Success: false,
-			}
-		}
-		return ProcessingResult{
-			FileID:  fileID,
-			Action:  "marked_failed",
-			Reason:  "no_batch_job_id",
-			Success: true,
-		}
+		return ProcessingResult{FileID: fileID, Action: "marked_failed", Reason: "no_batch_job_id", Success: true},
+			&pendingStatusUpdate{job: job, status: "failed", statusReason: "No batch job ID found"}
 	}
 
 	// Check actual Batch job status
@@ -198,40 +394,17 @@ func processStuckJob(job StuckJob) ProcessingResult {
 	batchResult, err := batchClient.DescribeJobs(describeInput)
 	if err != nil {
 		log.Printf("ERROR: Error checking batch job %s: %v", batchJobID, err)
-		// If we can't check the batch job, mark as failed
-		if err := updateStatusToFailed(fileID, fmt.Sprintf("Error checking batch job: %v", err)); err != nil {
-			return ProcessingResult{
-				FileID:  fileID,
-				Action:  "error",
-				Reason:  err.Error(),
-				Success: false,
-			}
-		}
-		return ProcessingResult{
-			FileID:  fileID,
-			Action:  "marked_failed",
-			Reason:  "batch_check_error",
-			Success: true,
-		}
+		statusReason := fmt.Sprintf("Error checking batch job: %v", err)
+		return ProcessingResult{FileID: fileID, Action: "marked_failed", Reason: "batch_check_error", Success: true},
+			&pendingStatusUpdate{job: job, status: "failed", statusReason: statusReason}
 	}
 
 	jobs := batchResult.Jobs
 	if len(jobs) == 0 {
 		log.Printf("WARNING: Batch job %s not found, marking as failed", batchJobID)
-		if err := updateStatusToFailed(fileID, fmt.Sprintf("Batch job %s not found", batchJobID)); err != nil {
-			return ProcessingResult{
-				FileID:  fileID,
-				Action:  "error",
-				Reason:  err.Error(),
-				Success: false,
-			}
-		}
-		return ProcessingResult{
-			FileID:  fileID,
-			Action:  "marked_failed",
-			Reason:  "batch_job_not_found",
-			Success: true,
-		}
+		statusReason := fmt.Sprintf("Batch job %s not found", batchJobID)
+		return ProcessingResult{FileID: fileID, Action: "marked_failed", Reason: "batch_job_not_found", Success: true},
+			&pendingStatusUpdate{job: job, status: "failed", statusReason: statusReason}
 	}
 
 	batchJob := jobs[0]
@@ -242,39 +415,38 @@ func processStuckJob(job StuckJob) ProcessingResult {
 	// Handle based on actual Batch status
 	switch batchStatus {
 	case "SUCCEEDED":
-		if err := updateStatusToProcessed(fileID, batchJobID); err != nil {
-			return ProcessingResult{
-				FileID:  fileID,
-				Action:  "error",
-				Reason:  err.Error(),
-				Success: false,
-			}
-		}
-		return ProcessingResult{
-			FileID:  fileID,
-			Action:  "marked_processed",
-			Reason:  "batch_job_succeeded",
-			Success: true,
-		}
+		return ProcessingResult{FileID: fileID, Action: "marked_processed", Reason: "batch_job_succeeded", Success: true},
+			&pendingStatusUpdate{job: job, status: "processed", statusReason: "batch_job_succeeded", batchJob: batchJob}
 	case "FAILED", "CANCELLED":
 		statusReason := "Batch job " + strings.ToLower(batchStatus)
 		if batchJob.StatusReason != nil {
 			statusReason = fmt.Sprintf("Batch job %s: %s", strings.ToLower(batchStatus), *batchJob.StatusReason)
 		}
-		if err := updateStatusToFailed(fileID, statusReason); err != nil {
-			return ProcessingResult{
-				FileID:  fileID,
-				Action:  "error",
-				Reason:  err.Error(),
-				Success: false,
+		history := appendFailureHistory(job, failureAttempt{
+			BatchJobID:    batchJobID,
+			StatusReason:  statusReason,
+			LogStreamLink: cloudWatchLogStreamLink(batchJob),
+			FailedAt:      time.Now().Unix(),
+		})
+
+		// A transient failure (a spot interruption, a throttled Textract
+		// call) deserves another attempt rather than being marked terminal
+		// on the first failure - resubmit with an exponentially backed-off
+		// delay until maxRetries is exhausted.
+		if job.RetryCount < maxRetries {
+			delaySeconds := backoffSeconds(job.RetryCount)
+			if err := enqueueRetry(job, delaySeconds); err != nil {
+				log.Printf("ERROR: Failed to enqueue retry for file %s, marking failed instead: %v", fileID, err)
+			} else {
+				nextRetryAt := time.Now().Unix() + delaySeconds
+				return ProcessingResult{FileID: fileID, Action: "retry_scheduled", Reason: fmt.Sprintf("batch_job_%s_retry_%d", strings.ToLower(batchStatus), job.RetryCount+1), Success: true},
+					&pendingStatusUpdate{job: job, status: "retrying", statusReason: statusReason, batchJob: batchJob, retryCount: job.RetryCount + 1, nextRetryAt: nextRetryAt, failureHistory: history}
 			}
 		}
-		return ProcessingResult{
-			FileID:  fileID,
-			Action:  "marked_failed",
-			Reason:  fmt.Sprintf("batch_job_%s", strings.ToLower(batchStatus)),
-			Success: true,
-		}
+
+		moveToDeadLetter(job, history)
+		return ProcessingResult{FileID: fileID, Action: "marked_failed", Reason: fmt.Sprintf("batch_job_%s_retries_exhausted", strings.ToLower(batchStatus)), Success: true},
+			&pendingStatusUpdate{job: job, status: "failed", statusReason: statusReason, batchJob: batchJob, failureHistory: history}
 	case "SUBMITTED", "PENDING", "RUNNABLE", "STARTING", "RUNNING":
 		// Job is still active in Batch, leave it alone for now
 		log.Printf("Batch job %s is still active (%s), leaving unchanged", batchJobID, batchStatus)
@@ -283,7 +455,7 @@ func processStuckJob(job StuckJob) ProcessingResult {
 			Action:  "no_change",
 			Reason:  fmt.Sprintf("batch_job_still_%s", strings.ToLower(batchStatus)),
 			Success: true,
-		}
+		}, nil
 	default:
 		log.Printf("WARNING: Unknown batch status %s for job %s", batchStatus, batchJobID)
 		return ProcessingResult{
@@ -291,77 +463,294 @@ func processStuckJob(job StuckJob) ProcessingResult {
 			Action:  "no_change",
 			Reason:  fmt.Sprintf("unknown_batch_status_%s", batchStatus),
 			Success: true,
-		}
+		}, nil
 	}
 }
 
-func updateStatusToProcessed(fileID, batchJobID string) error {
+// applyStatusUpdates rewrites every decided job's DynamoDB item via
+// BatchWriteItem instead of one UpdateItem per job: updates are chunked
+// into groups of 25 (BatchWriteItem's limit) and the chunks are submitted
+// concurrently, so a scan turning up thousands of stuck jobs fans out
+// across several round trips at once instead of one UpdateItem at a time.
+// BatchWriteItem can only PutItem a whole row, not apply an update
+// expression or a ConditionExpression, so each entry starts from the full
+// item findStuckProcessingJobs already read off the GSI and patches only
+// the fields dead-job resolution changes. It returns the set of file IDs
+// whose write never succeeded after retrying UnprocessedItems.
+func applyStatusUpdates(updates []pendingStatusUpdate) map[string]bool {
 	currentTime := time.Now().Unix()
 
-	// First, get the upload_timestamp for the composite key
-	uploadTimestamp, err := getUploadTimestamp(fileID)
+	writeRequests := make([]*dynamodb.WriteRequest, 0, len(updates))
+	for _, update := range updates {
+		item := make(map[string]*dynamodb.AttributeValue, len(update.job.item)+4)
+		for k, v := range update.job.item {
+			item[k] = v
+		}
+		item["last_updated"] = &dynamodb.AttributeValue{N: aws.String(fmt.Sprintf("%d", currentTime))}
+		switch update.status {
+		case "processed":
+			item["processing_status"] = &dynamodb.AttributeValue{S: aws.String("processed")}
+			item["processing_completed"] = &dynamodb.AttributeValue{N: aws.String(fmt.Sprintf("%d", currentTime))}
+			item["batch_job_final_status"] = &dynamodb.AttributeValue{S: aws.String("SUCCEEDED")}
+		case "retrying":
+			// Moved out of "processing" so the next scan's GSI query doesn't
+			// pick this row up again before the retry queue's delay elapses
+			// and resubmitRetry puts it back into "processing".
+			item["processing_status"] = &dynamodb.AttributeValue{S: aws.String("retrying")}
+			item["retry_count"] = &dynamodb.AttributeValue{N: aws.String(fmt.Sprintf("%d", update.retryCount))}
+			item["next_retry_at"] = &dynamodb.AttributeValue{N: aws.String(fmt.Sprintf("%d", update.nextRetryAt))}
+			item["failure_history"] = &dynamodb.AttributeValue{S: aws.String(update.failureHistory)}
+		default: // "failed"
+			item["processing_status"] = &dynamodb.AttributeValue{S: aws.String("failed")}
+			item["failed_at"] = &dynamodb.AttributeValue{N: aws.String(fmt.Sprintf("%d", currentTime))}
+			item["error_message"] = &dynamodb.AttributeValue{S: aws.String(fmt.Sprintf("Dead job detection: %s", update.statusReason))}
+			item["batch_job_final_status"] = &dynamodb.AttributeValue{S: aws.String("FAILED")}
+			item["failure_history"] = &dynamodb.AttributeValue{S: aws.String(update.failureHistory)}
+		}
+		writeRequests = append(writeRequests, &dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: item}})
+	}
+
+	var mu sync.Mutex
+	failed := make(map[string]bool)
+	group := new(errgroup.Group)
+	for i := 0; i < len(writeRequests); i += 25 {
+		end := i + 25
+		if end > len(writeRequests) {
+			end = len(writeRequests)
+		}
+		chunk := writeRequests[i:end]
+
+		group.Go(func() error {
+			unprocessed, err := batchWriteWithRetry(chunk)
+			if err != nil {
+				log.Printf("ERROR: BatchWriteItem failed: %v", err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			for _, req := range unprocessed {
+				fileID := aws.StringValue(req.PutRequest.Item["file_id"].S)
+				failed[fileID] = true
+			}
+			return nil
+		})
+	}
+	_ = group.Wait()
+
+	return failed
+}
+
+// batchWriteWithRetry submits one BatchWriteItem chunk, retrying
+// UnprocessedItems up to 3 times since BatchWriteItem silently drops items
+// it couldn't write (e.g. under throttling) rather than returning an
+// error. Whatever is still unprocessed after the last attempt, or if the
+// call itself errors, is returned to the caller to report as failed.
+func batchWriteWithRetry(chunk []*dynamodb.WriteRequest) ([]*dynamodb.WriteRequest, error) {
+	pending := chunk
+	for attempt := 0; attempt < 3 && len(pending) > 0; attempt++ {
+		result, err := dynamoClient.BatchWriteItem(&dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]*dynamodb.WriteRequest{dynamoTable: pending},
+		})
+		if err != nil {
+			return pending, err
+		}
+		pending = result.UnprocessedItems[dynamoTable]
+	}
+	return pending, nil
+}
+
+// backoffSeconds returns the retry delay for retryCount's upcoming
+// attempt: 1, 4, 16 minutes for retryCount 0, 1, 2, clamped to
+// maxSQSDelaySeconds since that's the most SendMessage's DelaySeconds
+// will ever honor.
+func backoffSeconds(retryCount int64) int64 {
+	delay := int64(60) << uint(2*retryCount)
+	if delay > maxSQSDelaySeconds {
+		return maxSQSDelaySeconds
+	}
+	return delay
+}
+
+// appendFailureHistory parses job's existing FailureHistory, appends
+// attempt, and returns the re-marshaled JSON for applyStatusUpdates to
+// persist - so a file that fails, retries, and fails again carries every
+// attempt's StatusReason forward instead of only its most recent one.
+func appendFailureHistory(job StuckJob, attempt failureAttempt) string {
+	var history []failureAttempt
+	if job.FailureHistory != "" {
+		if err := json.Unmarshal([]byte(job.FailureHistory), &history); err != nil {
+			log.Printf("WARNING: failed to parse failure history for file %s: %v", job.FileID, err)
+			history = nil
+		}
+	}
+	history = append(history, attempt)
+
+	data, err := json.Marshal(history)
 	if err != nil {
-		return fmt.Errorf("failed to get upload timestamp: %v", err)
+		log.Printf("WARNING: failed to marshal failure history for file %s: %v", job.FileID, err)
+		return job.FailureHistory
 	}
+	return string(data)
+}
 
-	updateInput := &dynamodb.UpdateItemInput{
-		TableName: aws.String(dynamoTable),
-		Key: map[string]*dynamodb.AttributeValue{
-			"file_id":          {S: aws.String(fileID)},
-			"upload_timestamp": {S: aws.String(uploadTimestamp)},
-		},
-		UpdateExpression: aws.String("SET processing_status = :status, processing_completed = :completed, last_updated = :updated, batch_job_final_status = :batch_status"),
-		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":status":       {S: aws.String("processed")},
-			":completed":    {N: aws.String(fmt.Sprintf("%d", currentTime))},
-			":updated":      {N: aws.String(fmt.Sprintf("%d", currentTime))},
-			":batch_status": {S: aws.String("SUCCEEDED")},
-		},
-		ConditionExpression: aws.String("attribute_exists(file_id)"),
+// cloudWatchLogStreamLink builds a console deep-link to a Batch job's most
+// recent attempt's log stream, so an operator reading a failure-history
+// entry doesn't have to reconstruct the /aws/batch/job log group path by
+// hand. It returns "" if batchJob never ran a container (e.g. CANCELLED
+// before dispatch).
+func cloudWatchLogStreamLink(batchJob *batch.JobDetail) string {
+	if batchJob == nil || len(batchJob.Attempts) == 0 {
+		return ""
+	}
+	last := batchJob.Attempts[len(batchJob.Attempts)-1]
+	if last.Container == nil || last.Container.LogStreamName == nil {
+		return ""
+	}
+
+	region := os.Getenv("AWS_REGION")
+	return fmt.Sprintf(
+		"https://%s.console.aws.amazon.com/cloudwatch/home?region=%s#logEventViewer:group=/aws/batch/job;stream=%s",
+		region, region, *last.Container.LogStreamName,
+	)
+}
+
+// enqueueRetry schedules job's resubmission by sending a message to
+// RETRY_QUEUE_URL delayed by delaySeconds. Unlike the best-effort
+// report/DLQ writes below, a failure here must surface to the caller -
+// processStuckJob falls back to the terminal failure path so a retry that
+// can't be scheduled doesn't leave the file silently stuck in "retrying".
+func enqueueRetry(job StuckJob, delaySeconds int64) error {
+	if retryQueueURL == "" {
+		return fmt.Errorf("RETRY_QUEUE_URL not configured")
+	}
+
+	record, err := getFileRecord(job.FileID)
+	if err != nil {
+		return fmt.Errorf("failed to look up file location: %v", err)
 	}
 
-	_, err = dynamoClient.UpdateItem(updateInput)
+	body, err := json.Marshal(retryMessage{FileID: job.FileID, BucketName: record.BucketName, ObjectKey: record.S3Key})
 	if err != nil {
-		return fmt.Errorf("failed to update file %s to processed: %v", fileID, err)
+		return fmt.Errorf("failed to marshal retry message: %v", err)
 	}
 
-	log.Printf("Updated file_id %s to processed status", fileID)
+	if _, err := sqsClient.SendMessage(&sqs.SendMessageInput{
+		QueueUrl:     aws.String(retryQueueURL),
+		MessageBody:  aws.String(string(body)),
+		DelaySeconds: aws.Int64(delaySeconds),
+	}); err != nil {
+		return fmt.Errorf("failed to send retry message: %v", err)
+	}
 	return nil
 }
 
-func updateStatusToFailed(fileID, errorMessage string) error {
-	currentTime := time.Now().Unix()
+// handleRetryMessages resubmits each delivered retry message's Batch job.
+// It is best-effort per record - one bad message shouldn't block the rest
+// of the batch SQS hands the Lambda.
+func handleRetryMessages(sqsEvent events.SQSEvent) (Response, error) {
+	resubmitted := 0
+	for _, record := range sqsEvent.Records {
+		var msg retryMessage
+		if err := json.Unmarshal([]byte(record.Body), &msg); err != nil {
+			log.Printf("ERROR: invalid retry message: %v", err)
+			continue
+		}
+		if err := resubmitRetry(msg); err != nil {
+			log.Printf("ERROR: failed to resubmit retry for file %s: %v", msg.FileID, err)
+			continue
+		}
+		resubmitted++
+	}
 
-	// First, get the upload_timestamp for the composite key
-	uploadTimestamp, err := getUploadTimestamp(fileID)
+	return Response{
+		StatusCode: 200,
+		Body: map[string]interface{}{
+			"message":     fmt.Sprintf("Resubmitted %d of %d retries", resubmitted, len(sqsEvent.Records)),
+			"resubmitted": resubmitted,
+		},
+	}, nil
+}
+
+// resubmitRetry submits msg's file through batchjob.Submit - the same
+// helper sqs_processor uses on first upload and handleRetryJob uses for a
+// manual retry - and moves the file back to "processing" with a fresh
+// processing_started, so the next dead-job scan gives it the full
+// MAX_PROCESSING_MINUTES window again instead of treating it as already
+// stuck.
+func resubmitRetry(msg retryMessage) error {
+	jobID, jobName, err := batchjob.Submit(batchClient, batchjob.SubmitInput{
+		BucketName:    msg.BucketName,
+		ObjectKey:     msg.ObjectKey,
+		FileID:        msg.FileID,
+		JobQueue:      jobQueue,
+		JobDefinition: jobDefinition,
+		DynamoTable:   dynamoTable,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to resubmit batch job: %v", err)
+	}
+
+	uploadTimestamp, err := getUploadTimestamp(msg.FileID)
 	if err != nil {
 		return fmt.Errorf("failed to get upload timestamp: %v", err)
 	}
 
+	currentTime := time.Now().Unix()
 	updateInput := &dynamodb.UpdateItemInput{
 		TableName: aws.String(dynamoTable),
 		Key: map[string]*dynamodb.AttributeValue{
-			"file_id":          {S: aws.String(fileID)},
+			"file_id":          {S: aws.String(msg.FileID)},
 			"upload_timestamp": {S: aws.String(uploadTimestamp)},
 		},
-		UpdateExpression: aws.String("SET processing_status = :status, failed_at = :failed_at, last_updated = :updated, error_message = :error, batch_job_final_status = :batch_status"),
+		UpdateExpression: aws.String("SET processing_status = :status, batch_job_id = :job_id, batch_job_name = :job_name, processing_started = :started, last_updated = :updated"),
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":status":       {S: aws.String("failed")},
-			":failed_at":    {N: aws.String(fmt.Sprintf("%d", currentTime))},
-			":updated":      {N: aws.String(fmt.Sprintf("%d", currentTime))},
-			":error":        {S: aws.String(fmt.Sprintf("Dead job detection: %s", errorMessage))},
-			":batch_status": {S: aws.String("FAILED")},
+			":status":   {S: aws.String("processing")},
+			":job_id":   {S: aws.String(jobID)},
+			":job_name": {S: aws.String(jobName)},
+			":started":  {N: aws.String(fmt.Sprintf("%d", currentTime))},
+			":updated":  {S: aws.String(time.Now().UTC().Format(time.RFC3339))},
 		},
 		ConditionExpression: aws.String("attribute_exists(file_id)"),
 	}
+	if _, err := dynamoClient.UpdateItem(updateInput); err != nil {
+		return fmt.Errorf("failed to update file %s after scheduled retry: %v", msg.FileID, err)
+	}
+
+	log.Printf("Resubmitted file_id %s as batch job %s (scheduled retry)", msg.FileID, jobID)
+	return nil
+}
+
+// moveToDeadLetter writes job's full failure history to DLQ_TABLE once
+// retries are exhausted, so an operator investigating a permanently failed
+// file has every attempt's StatusReason and log stream link in one place
+// rather than having to reconstruct it from CloudWatch. It is best-effort
+// and DLQ_TABLE is optional, mirroring writeStuckJobReport.
+func moveToDeadLetter(job StuckJob, failureHistory string) {
+	if dlqTable == "" {
+		return
+	}
 
-	_, err = dynamoClient.UpdateItem(updateInput)
+	item, err := dynamodbattribute.MarshalMap(struct {
+		FileID         string `dynamodbav:"file_id"`
+		BatchJobID     string `dynamodbav:"batch_job_id"`
+		RetryCount     int64  `dynamodbav:"retry_count"`
+		FailureHistory string `dynamodbav:"failure_history"`
+		MovedAt        int64  `dynamodbav:"moved_at"`
+	}{
+		FileID:         job.FileID,
+		BatchJobID:     job.BatchJobID,
+		RetryCount:     job.RetryCount,
+		FailureHistory: failureHistory,
+		MovedAt:        time.Now().Unix(),
+	})
 	if err != nil {
-		return fmt.Errorf("failed to update file %s to failed: %v", fileID, err)
+		log.Printf("WARNING: failed to marshal dead-letter record for file %s: %v", job.FileID, err)
+		return
 	}
 
-	log.Printf("Updated file_id %s to failed status: %s", fileID, errorMessage)
-	return nil
+	if _, err := dynamoClient.PutItem(&dynamodb.PutItemInput{TableName: aws.String(dlqTable), Item: item}); err != nil {
+		log.Printf("WARNING: failed to write dead-letter record for file %s: %v", job.FileID, err)
+	}
 }
 
 func getUploadTimestamp(fileID string) (string, error) {
@@ -389,4 +778,291 @@ func getUploadTimestamp(fileID string) (string, error) {
 	}
 
 	return *uploadTimestamp.S, nil
+}
+
+// fileRecord is the subset of a file_uploader FileMetadata row the
+// job-control endpoints below need to act on a single file.
+type fileRecord struct {
+	FileID           string `dynamodbav:"file_id"`
+	UploadTimestamp  string `dynamodbav:"upload_timestamp"`
+	BucketName       string `dynamodbav:"bucket_name"`
+	S3Key            string `dynamodbav:"s3_key"`
+	ProcessingStatus string `dynamodbav:"processing_status"`
+	BatchJobID       string `dynamodbav:"batch_job_id"`
+}
+
+func getFileRecord(fileID string) (*fileRecord, error) {
+	queryInput := &dynamodb.QueryInput{
+		TableName:              aws.String(dynamoTable),
+		KeyConditionExpression: aws.String("file_id = :file_id"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":file_id": {S: aws.String(fileID)},
+		},
+		Limit: aws.Int64(1),
+	}
+
+	result, err := dynamoClient.Query(queryInput)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query file metadata: %v", err)
+	}
+	if len(result.Items) == 0 {
+		return nil, fmt.Errorf("file metadata not found for file_id: %s", fileID)
+	}
+
+	var record fileRecord
+	if err := dynamodbattribute.UnmarshalMap(result.Items[0], &record); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal file metadata: %v", err)
+	}
+	return &record, nil
+}
+
+// JobActionResponse is returned from POST /jobs/{fileId}/cancel and
+// POST /jobs/{fileId}/retry.
+type JobActionResponse struct {
+	FileID string `json:"fileId"`
+	Action string `json:"action"`
+	Status string `json:"status"`
+}
+
+// JobStatusResponse is returned from GET /jobs/{fileId}/status. It merges
+// the DynamoDB processing_status with Batch's own live status so an
+// operator doesn't have to check both systems separately.
+type JobStatusResponse struct {
+	FileID            string `json:"fileId"`
+	ProcessingStatus  string `json:"processingStatus"`
+	BatchJobID        string `json:"batchJobId,omitempty"`
+	BatchStatus       string `json:"batchStatus,omitempty"`
+	BatchStatusReason string `json:"batchStatusReason,omitempty"`
+}
+
+// routeJobControl dispatches the operator-facing job-control API added
+// alongside the scheduled dead-job scan above: POST /jobs/{fileId}/cancel,
+// POST /jobs/{fileId}/retry, and GET /jobs/{fileId}/status let an operator
+// intervene on a stuck file without waiting for the next scan.
+func routeJobControl(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	headers := map[string]string{"Content-Type": "application/json"}
+
+	segments := strings.Split(strings.Trim(request.Path, "/"), "/")
+	if len(segments) != 3 || segments[0] != "jobs" {
+		return jobControlError(headers, 404, fmt.Sprintf("No job-control route for %s %s", request.HTTPMethod, request.Path))
+	}
+	fileID := segments[1]
+
+	switch {
+	case request.HTTPMethod == "POST" && segments[2] == "cancel":
+		return handleCancelJob(fileID, headers)
+	case request.HTTPMethod == "POST" && segments[2] == "retry":
+		return handleRetryJob(fileID, headers)
+	case request.HTTPMethod == "GET" && segments[2] == "status":
+		return handleJobStatus(fileID, headers)
+	default:
+		return jobControlError(headers, 404, fmt.Sprintf("No job-control route for %s %s", request.HTTPMethod, request.Path))
+	}
+}
+
+// handleCancelJob implements POST /jobs/{fileId}/cancel: it asks Batch to
+// terminate the job directly rather than waiting for the next scheduled
+// scan to notice it never finished.
+func handleCancelJob(fileID string, headers map[string]string) (events.APIGatewayProxyResponse, error) {
+	record, err := getFileRecord(fileID)
+	if err != nil {
+		return jobControlError(headers, 404, err.Error())
+	}
+	if record.BatchJobID == "" {
+		return jobControlError(headers, 400, fmt.Sprintf("file %s has no batch job to cancel", fileID))
+	}
+
+	if _, err := batchClient.TerminateJob(&batch.TerminateJobInput{
+		JobId:  aws.String(record.BatchJobID),
+		Reason: aws.String("Cancelled via job-control API"),
+	}); err != nil {
+		log.Printf("ERROR: Failed to terminate batch job %s for file %s: %v", record.BatchJobID, fileID, err)
+		return jobControlError(headers, 500, fmt.Sprintf("failed to terminate batch job: %v", err))
+	}
+
+	if err := updateStatusToCancelled(fileID, "Cancelled via job-control API"); err != nil {
+		return jobControlError(headers, 500, err.Error())
+	}
+
+	return jobControlResponse(headers, 200, JobActionResponse{FileID: fileID, Action: "cancel", Status: "cancelled"})
+}
+
+// handleRetryJob implements POST /jobs/{fileId}/retry: it resubmits the
+// file through batchjob.Submit, the same helper sqs_processor uses on
+// first upload, with the BATCH_JOB_QUEUE/BATCH_JOB_DEFINITION pair and
+// none of the per-template settings from pkg/jobconfig.
+func handleRetryJob(fileID string, headers map[string]string) (events.APIGatewayProxyResponse, error) {
+	if jobQueue == "" || jobDefinition == "" {
+		return jobControlError(headers, 500, "BATCH_JOB_QUEUE and BATCH_JOB_DEFINITION must both be set to retry a job")
+	}
+
+	record, err := getFileRecord(fileID)
+	if err != nil {
+		return jobControlError(headers, 404, err.Error())
+	}
+
+	jobID, jobName, err := batchjob.Submit(batchClient, batchjob.SubmitInput{
+		BucketName:    record.BucketName,
+		ObjectKey:     record.S3Key,
+		FileID:        fileID,
+		JobQueue:      jobQueue,
+		JobDefinition: jobDefinition,
+		DynamoTable:   dynamoTable,
+	})
+	if err != nil {
+		log.Printf("ERROR: Failed to resubmit batch job for file %s: %v", fileID, err)
+		return jobControlError(headers, 500, fmt.Sprintf("failed to resubmit batch job: %v", err))
+	}
+
+	if err := updateStatusToRetried(record.UploadTimestamp, fileID, jobID, jobName); err != nil {
+		return jobControlError(headers, 500, err.Error())
+	}
+
+	return jobControlResponse(headers, 200, JobActionResponse{FileID: fileID, Action: "retry", Status: "processing"})
+}
+
+// handleJobStatus implements GET /jobs/{fileId}/status.
+func handleJobStatus(fileID string, headers map[string]string) (events.APIGatewayProxyResponse, error) {
+	record, err := getFileRecord(fileID)
+	if err != nil {
+		return jobControlError(headers, 404, err.Error())
+	}
+
+	response := JobStatusResponse{
+		FileID:           fileID,
+		ProcessingStatus: record.ProcessingStatus,
+		BatchJobID:       record.BatchJobID,
+	}
+
+	if record.BatchJobID != "" {
+		batchResult, err := batchClient.DescribeJobs(&batch.DescribeJobsInput{
+			Jobs: []*string{aws.String(record.BatchJobID)},
+		})
+		if err != nil {
+			log.Printf("WARNING: Failed to describe batch job %s for file %s: %v", record.BatchJobID, fileID, err)
+		} else if len(batchResult.Jobs) > 0 {
+			job := batchResult.Jobs[0]
+			response.BatchStatus = aws.StringValue(job.Status)
+			response.BatchStatusReason = aws.StringValue(job.StatusReason)
+		}
+	}
+
+	return jobControlResponse(headers, 200, response)
+}
+
+func updateStatusToCancelled(fileID, reason string) error {
+	currentTime := time.Now().Unix()
+
+	uploadTimestamp, err := getUploadTimestamp(fileID)
+	if err != nil {
+		return fmt.Errorf("failed to get upload timestamp: %v", err)
+	}
+
+	updateInput := &dynamodb.UpdateItemInput{
+		TableName: aws.String(dynamoTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"file_id":          {S: aws.String(fileID)},
+			"upload_timestamp": {S: aws.String(uploadTimestamp)},
+		},
+		UpdateExpression: aws.String("SET processing_status = :status, failed_at = :failed_at, last_updated = :updated, error_message = :error, batch_job_final_status = :batch_status"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":status":       {S: aws.String("cancelled")},
+			":failed_at":    {N: aws.String(fmt.Sprintf("%d", currentTime))},
+			":updated":      {N: aws.String(fmt.Sprintf("%d", currentTime))},
+			":error":        {S: aws.String(reason)},
+			":batch_status": {S: aws.String("CANCELLED")},
+		},
+		ConditionExpression: aws.String("attribute_exists(file_id)"),
+	}
+
+	if _, err := dynamoClient.UpdateItem(updateInput); err != nil {
+		return fmt.Errorf("failed to update file %s to cancelled: %v", fileID, err)
+	}
+
+	log.Printf("Updated file_id %s to cancelled status: %s", fileID, reason)
+	return nil
+}
+
+func updateStatusToRetried(uploadTimestamp, fileID, jobID, jobName string) error {
+	// processing_started must be refreshed to a fresh Unix-epoch Number
+	// here too - same as resubmitRetry's automatic retry path - or the
+	// stale original timestamp leaves this file already past
+	// MAX_PROCESSING_MINUTES, and the next dead-job scan immediately
+	// re-flags the job this endpoint just retried as stuck.
+	updateInput := &dynamodb.UpdateItemInput{
+		TableName: aws.String(dynamoTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"file_id":          {S: aws.String(fileID)},
+			"upload_timestamp": {S: aws.String(uploadTimestamp)},
+		},
+		UpdateExpression: aws.String("SET processing_status = :status, batch_job_id = :job_id, batch_job_name = :job_name, processing_started = :started, last_updated = :updated"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":status":   {S: aws.String("processing")},
+			":job_id":   {S: aws.String(jobID)},
+			":job_name": {S: aws.String(jobName)},
+			":started":  {N: aws.String(fmt.Sprintf("%d", time.Now().Unix()))},
+			":updated":  {S: aws.String(time.Now().UTC().Format(time.RFC3339))},
+		},
+		ConditionExpression: aws.String("attribute_exists(file_id)"),
+	}
+
+	if _, err := dynamoClient.UpdateItem(updateInput); err != nil {
+		return fmt.Errorf("failed to update file %s after retry: %v", fileID, err)
+	}
+
+	log.Printf("Resubmitted file_id %s as batch job %s", fileID, jobID)
+	return nil
+}
+
+func jobControlResponse(headers map[string]string, statusCode int, body interface{}) (events.APIGatewayProxyResponse, error) {
+	responseBody, _ := json.Marshal(body)
+	return events.APIGatewayProxyResponse{StatusCode: statusCode, Headers: headers, Body: string(responseBody)}, nil
+}
+
+func jobControlError(headers map[string]string, statusCode int, message string) (events.APIGatewayProxyResponse, error) {
+	return jobControlResponse(headers, statusCode, map[string]string{"error": message})
+}
+
+// writeStuckJobReport persists a durable BatchJobReport to S3 for a
+// resolved stuck job, using whatever Batch job detail is available - there
+// may be none, if batchJobID was empty or the job has vanished from Batch
+// entirely. It is best-effort and REPORTS_BUCKET_NAME is optional.
+func writeStuckJobReport(job StuckJob, status, statusReason string, batchJob *batch.JobDetail) {
+	if reportStore == nil {
+		return
+	}
+
+	report := batchreport.Report{
+		JobID:        job.BatchJobID,
+		FinishedAt:   time.Now().UTC().Format(time.RFC3339),
+		StatusReason: statusReason,
+	}
+	if status == "processed" {
+		report.TotalNumberOfTasks, report.NumberOfTasksSucceeded = 1, 1
+	} else {
+		report.TotalNumberOfTasks, report.NumberOfTasksFailed = 1, 1
+	}
+	if report.JobID == "" {
+		report.JobID = fmt.Sprintf("no-batch-job-%s", job.FileID)
+	}
+
+	if record, err := getFileRecord(job.FileID); err != nil {
+		log.Printf("WARNING: Failed to look up file location for report %s: %v", report.JobID, err)
+	} else {
+		report.Files = []batchreport.FileDetail{{FileID: job.FileID, S3Key: record.S3Key, Status: status}}
+	}
+
+	if batchJob != nil {
+		report.JobName = aws.StringValue(batchJob.JobName)
+		if batchJob.StartedAt != nil {
+			report.StartedAt = time.UnixMilli(*batchJob.StartedAt).UTC().Format(time.RFC3339)
+		}
+		if batchJob.StoppedAt != nil {
+			report.FinishedAt = time.UnixMilli(*batchJob.StoppedAt).UTC().Format(time.RFC3339)
+		}
+	}
+
+	if err := batchreport.Write(context.Background(), reportStore, report); err != nil {
+		log.Printf("WARNING: Failed to write batch job report for file %s: %v", job.FileID, err)
+	}
 }
\ No newline at end of file
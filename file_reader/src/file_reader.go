@@ -2,11 +2,13 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"strconv"
+	"sync"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
@@ -14,8 +16,12 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"golang.org/x/sync/errgroup"
 )
 
+// maxBatchGetKeys is the DynamoDB BatchGetItem per-request key limit.
+const maxBatchGetKeys = 100
+
 // FileMetadata represents DynamoDB file metadata structure
 type FileMetadata struct {
 	FileID           string `dynamodbav:"file_id" json:"fileId"`
@@ -38,6 +44,14 @@ type ProcessingResult struct {
 	ProcessingDuration  string                 `dynamodbav:"processing_duration"`
 	ComprehendAnalysis  map[string]interface{} `dynamodbav:"comprehend_analysis"`
 	TextractAnalysis    map[string]interface{} `dynamodbav:"textract_analysis"`
+	StageUpdates        map[string]StageUpdate `dynamodbav:"stage_updates"`
+}
+
+// StageUpdate is a single checkpoint written by the batch worker, keyed by
+// stage name, as it moves through a file's processing stages.
+type StageUpdate struct {
+	StartedAt   string `dynamodbav:"started_at" json:"startedAt"`
+	CompletedAt string `dynamodbav:"completed_at" json:"completedAt,omitempty"`
 }
 
 // Response structures
@@ -59,14 +73,19 @@ type SingleFileResponse struct {
 	TextFormatting     map[string]interface{} `json:"textFormatting,omitempty"`
 	Analysis           map[string]interface{} `json:"analysis,omitempty"`
 	ProcessingDuration string                 `json:"processingDuration,omitempty"`
-	ComprehendAnalysis map[string]interface{} `json:"comprehendAnalysis,omitempty"`
-	TextractAnalysis   map[string]interface{} `json:"textractAnalysis,omitempty"`
+	ComprehendAnalysis   map[string]interface{} `json:"comprehendAnalysis,omitempty"`
+	TextractAnalysis     map[string]interface{} `json:"textractAnalysis,omitempty"`
+	Highlights           []string               `json:"highlights,omitempty"`
+	ProgressPercent      int                    `json:"progressPercent,omitempty"`
+	CurrentStage         string                 `json:"currentStage,omitempty"`
+	EstimatedCompletion  string                 `json:"estimatedCompletion,omitempty"`
 }
 
 type MultiFileResponse struct {
-	Files   []SingleFileResponse `json:"files"`
-	Count   int                  `json:"count"`
-	HasMore bool                 `json:"hasMore"`
+	Files      []SingleFileResponse `json:"files"`
+	Count      int                  `json:"count"`
+	HasMore    bool                 `json:"hasMore"`
+	NextCursor string               `json:"nextCursor,omitempty"`
 }
 
 var (
@@ -134,13 +153,58 @@ func handleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (
 	}
 
 	fileID := queryParams["fileId"]
+	cursor := queryParams["cursor"]
+	fields := parseFieldsParam(queryParams["fields"])
+
+	var response events.APIGatewayProxyResponse
+	var err error
 
 	// If specific file_id is requested
 	if fileID != "" {
-		return handleSingleFileRequest(fileID, headers)
+		response, err = handleSingleFileRequest(fileID, headers)
+	} else if queryParams["q"] != "" && searchClient != nil {
+		response, err = handleSearchRequest(queryParams, limit, headers)
 	} else {
-		return handleMultipleFilesRequest(statusFilter, limit, headers)
+		response, err = handleMultipleFilesRequest(statusFilter, limit, cursor, headers)
+	}
+	if err != nil {
+		return response, err
+	}
+
+	return finalizeResponse(request, response, fields)
+}
+
+// decodeCursor turns a base64-encoded LastEvaluatedKey back into a DynamoDB key map.
+func decodeCursor(cursor string) (map[string]*dynamodb.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.URLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor encoding: %w", err)
+	}
+
+	var key map[string]*dynamodb.AttributeValue
+	if err := json.Unmarshal(raw, &key); err != nil {
+		return nil, fmt.Errorf("invalid cursor payload: %w", err)
+	}
+
+	return key, nil
+}
+
+// encodeCursor base64-encodes a LastEvaluatedKey so clients can page past limit.
+func encodeCursor(lastEvaluatedKey map[string]*dynamodb.AttributeValue) (string, error) {
+	if len(lastEvaluatedKey) == 0 {
+		return "", nil
+	}
+
+	raw, err := json.Marshal(lastEvaluatedKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal cursor: %w", err)
 	}
+
+	return base64.URLEncoding.EncodeToString(raw), nil
 }
 
 func handleSingleFileRequest(fileID string, headers map[string]string) (events.APIGatewayProxyResponse, error) {
@@ -219,6 +283,11 @@ func handleSingleFileRequest(fileID string, headers map[string]string) (events.A
 		responseData.TextractAnalysis = processingResult.TextractAnalysis
 	}
 
+	if fileMetadata.ProcessingStatus == "uploaded" || fileMetadata.ProcessingStatus == "processing" {
+		responseData.ProgressPercent, responseData.CurrentStage = synthesizeProgress(processingResult.StageUpdates)
+		responseData.EstimatedCompletion = estimateCompletion(fileMetadata, processingResult.StageUpdates)
+	}
+
 	responseBody, err := json.Marshal(responseData)
 	if err != nil {
 		return createErrorResponse(500, "JSON Error", fmt.Sprintf("Failed to marshal response: %v", err))
@@ -231,23 +300,28 @@ func handleSingleFileRequest(fileID string, headers map[string]string) (events.A
 	}, nil
 }
 
-func handleMultipleFilesRequest(statusFilter string, limit int64, headers map[string]string) (events.APIGatewayProxyResponse, error) {
-	var queryResult *dynamodb.QueryOutput
-	var scanResult *dynamodb.ScanOutput
-	var err error
+func handleMultipleFilesRequest(statusFilter string, limit int64, cursor string, headers map[string]string) (events.APIGatewayProxyResponse, error) {
+	exclusiveStartKey, err := decodeCursor(cursor)
+	if err != nil {
+		return createErrorResponse(400, "Bad Request", err.Error())
+	}
+
+	var lastEvaluatedKey map[string]*dynamodb.AttributeValue
 	var items []map[string]*dynamodb.AttributeValue
 
 	if statusFilter == "all" {
 		// Scan all files (less efficient but necessary for 'all')
 		scanInput := &dynamodb.ScanInput{
-			TableName: aws.String(metadataTableName),
-			Limit:     aws.Int64(limit),
+			TableName:         aws.String(metadataTableName),
+			Limit:             aws.Int64(limit),
+			ExclusiveStartKey: exclusiveStartKey,
 		}
-		scanResult, err = dynamoClient.Scan(scanInput)
+		scanResult, err := dynamoClient.Scan(scanInput)
 		if err != nil {
 			return createErrorResponse(500, "Database Error", fmt.Sprintf("Failed to scan metadata: %v", err))
 		}
 		items = scanResult.Items
+		lastEvaluatedKey = scanResult.LastEvaluatedKey
 	} else {
 		// Query by status using GSI
 		queryInput := &dynamodb.QueryInput{
@@ -257,56 +331,50 @@ func handleMultipleFilesRequest(statusFilter string, limit int64, headers map[st
 			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
 				":status": {S: aws.String(statusFilter)},
 			},
-			Limit:            aws.Int64(limit),
-			ScanIndexForward: aws.Bool(false), // Most recent first
+			Limit:             aws.Int64(limit),
+			ScanIndexForward:  aws.Bool(false), // Most recent first
+			ExclusiveStartKey: exclusiveStartKey,
 		}
-		queryResult, err = dynamoClient.Query(queryInput)
+		queryResult, err := dynamoClient.Query(queryInput)
 		if err != nil {
 			return createErrorResponse(500, "Database Error", fmt.Sprintf("Failed to query by status: %v", err))
 		}
 		items = queryResult.Items
+		lastEvaluatedKey = queryResult.LastEvaluatedKey
 	}
 
-	// Process items and enrich with CloudFront URLs and results
-	var processedItems []SingleFileResponse
+	// Unmarshal metadata up front so we know which file_ids need a results lookup.
+	fileMetadataByID := make(map[string]FileMetadata, len(items))
+	var processedFileIDs []string
 	for _, item := range items {
 		var fileMetadata FileMetadata
 		if err := dynamodbattribute.UnmarshalMap(item, &fileMetadata); err != nil {
 			log.Printf("Failed to unmarshal metadata: %v", err)
 			continue
 		}
-
-		// Get processing results if status is processed
-		var processingResult ProcessingResult
+		fileMetadataByID[fileMetadata.FileID] = fileMetadata
 		if fileMetadata.ProcessingStatus == "processed" {
-			getInput := &dynamodb.GetItemInput{
-				TableName: aws.String(resultsTableName),
-				Key: map[string]*dynamodb.AttributeValue{
-					"file_id": {S: aws.String(fileMetadata.FileID)},
-				},
-			}
+			processedFileIDs = append(processedFileIDs, fileMetadata.FileID)
+		}
+	}
 
-			resultResult, err := dynamoClient.GetItem(getInput)
-			if err != nil {
-				log.Printf("Failed to get processing results for %s: %v", fileMetadata.FileID, err)
-			} else if resultResult.Item != nil {
-				if err := dynamodbattribute.UnmarshalMap(resultResult.Item, &processingResult); err != nil {
-					log.Printf("Failed to unmarshal processing results for %s: %v", fileMetadata.FileID, err)
-				} else {
-					// Validate that essential data was retrieved
-					if processingResult.ExtractedText == "" && len(processingResult.Analysis) == 0 {
-						log.Printf("Warning: Processing results for %s appear to be incomplete - missing extracted text and analysis", fileMetadata.FileID)
-					}
-				}
-			} else {
-				log.Printf("Warning: No processing results found for %s in table %s", fileMetadata.FileID, resultsTableName)
-			}
+	// Fetch processing results with BatchGetItem instead of one GetItem per file,
+	// chunking at the 100-key BatchGetItem limit and fetching chunks concurrently.
+	processingResults, err := batchGetProcessingResults(processedFileIDs)
+	if err != nil {
+		log.Printf("Failed to batch-fetch processing results: %v", err)
+	}
+
+	// Build the response preserving the original item order.
+	var processedItems []SingleFileResponse
+	for _, item := range items {
+		var fileMetadata FileMetadata
+		if err := dynamodbattribute.UnmarshalMap(item, &fileMetadata); err != nil {
+			continue
 		}
 
-		// Generate CloudFront URL
 		cloudFrontURL := fmt.Sprintf("https://%s/%s", cloudFrontDomain, fileMetadata.S3Key)
 
-		// Build item data
 		itemData := SingleFileResponse{
 			FileID:           fileMetadata.FileID,
 			FileName:         fileMetadata.FileName,
@@ -317,8 +385,7 @@ func handleMultipleFilesRequest(statusFilter string, limit int64, headers map[st
 			CloudFrontURL:    cloudFrontURL,
 		}
 
-		// Add processing results if available and status is processed - check for actual data
-		if fileMetadata.ProcessingStatus == "processed" && (processingResult.ExtractedText != "" || len(processingResult.Analysis) > 0 || len(processingResult.ComprehendAnalysis) > 0 || len(processingResult.TextractAnalysis) > 0) {
+		if processingResult, ok := processingResults[fileMetadata.FileID]; ok {
 			itemData.ExtractedText = processingResult.ExtractedText
 			itemData.FormattedText = processingResult.FormattedText
 			itemData.TextFormatting = processingResult.TextFormatting
@@ -331,18 +398,16 @@ func handleMultipleFilesRequest(statusFilter string, limit int64, headers map[st
 		processedItems = append(processedItems, itemData)
 	}
 
-	// Determine if there are more items
-	hasMore := false
-	if statusFilter == "all" && scanResult != nil {
-		hasMore = scanResult.LastEvaluatedKey != nil
-	} else if queryResult != nil {
-		hasMore = queryResult.LastEvaluatedKey != nil
+	nextCursor, err := encodeCursor(lastEvaluatedKey)
+	if err != nil {
+		log.Printf("Failed to encode next cursor: %v", err)
 	}
 
 	responseData := MultiFileResponse{
-		Files:   processedItems,
-		Count:   len(processedItems),
-		HasMore: hasMore,
+		Files:      processedItems,
+		Count:      len(processedItems),
+		HasMore:    lastEvaluatedKey != nil,
+		NextCursor: nextCursor,
 	}
 
 	responseBody, err := json.Marshal(responseData)
@@ -357,6 +422,74 @@ func handleMultipleFilesRequest(statusFilter string, limit int64, headers map[st
 	}, nil
 }
 
+// batchGetProcessingResults fetches results rows for the given file IDs using
+// BatchGetItem instead of one GetItem round-trip per file, chunked at the
+// DynamoDB 100-key limit with chunks fetched concurrently via errgroup.
+func batchGetProcessingResults(fileIDs []string) (map[string]ProcessingResult, error) {
+	results := make(map[string]ProcessingResult, len(fileIDs))
+	if len(fileIDs) == 0 {
+		return results, nil
+	}
+
+	var chunks [][]string
+	for i := 0; i < len(fileIDs); i += maxBatchGetKeys {
+		end := i + maxBatchGetKeys
+		if end > len(fileIDs) {
+			end = len(fileIDs)
+		}
+		chunks = append(chunks, fileIDs[i:end])
+	}
+
+	var mu sync.Mutex
+	g, _ := errgroup.WithContext(context.Background())
+
+	for _, chunk := range chunks {
+		chunk := chunk
+		g.Go(func() error {
+			keys := make([]map[string]*dynamodb.AttributeValue, len(chunk))
+			for i, fileID := range chunk {
+				keys[i] = map[string]*dynamodb.AttributeValue{
+					"file_id": {S: aws.String(fileID)},
+				}
+			}
+
+			remaining := map[string]*dynamodb.KeysAndAttributes{
+				resultsTableName: {Keys: keys},
+			}
+
+			for len(remaining) > 0 {
+				batchResult, err := dynamoClient.BatchGetItem(&dynamodb.BatchGetItemInput{
+					RequestItems: remaining,
+				})
+				if err != nil {
+					return fmt.Errorf("batch get item failed: %w", err)
+				}
+
+				mu.Lock()
+				for _, item := range batchResult.Responses[resultsTableName] {
+					var processingResult ProcessingResult
+					if err := dynamodbattribute.UnmarshalMap(item, &processingResult); err != nil {
+						log.Printf("Failed to unmarshal processing result: %v", err)
+						continue
+					}
+					results[processingResult.FileID] = processingResult
+				}
+				mu.Unlock()
+
+				remaining = batchResult.UnprocessedKeys
+			}
+
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return results, err
+	}
+
+	return results, nil
+}
+
 func createErrorResponse(statusCode int, errorType, message string) (events.APIGatewayProxyResponse, error) {
 	headers := map[string]string{
 		"Content-Type":                "application/json",
@@ -0,0 +1,134 @@
+package main
+
+// progress.go turns the batch worker's per-stage checkpoints (stage_updates
+// on the results row) into a progress bar the UI can render for in-flight
+// jobs, instead of the binary "uploaded"/"processing"/"processed" states.
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// stageOrder lists the processing stages in the order the batch worker
+// writes a checkpoint for each. stageWeights gives the cumulative percent
+// complete once that stage's checkpoint is marked completed.
+var stageOrder = []string{"queued", "textract", "comprehend", "formatting"}
+
+var stageWeights = map[string]int{
+	"queued":     5,
+	"textract":   60,
+	"comprehend": 90,
+	"formatting": 100,
+}
+
+// recentSamplesForETA caps how many comparable completed files are scanned
+// to compute a rolling-average processing duration.
+const recentSamplesForETA = 20
+
+// synthesizeProgress derives a 0-100 percent complete and the name of the
+// current stage from the checkpoints written so far.
+func synthesizeProgress(updates map[string]StageUpdate) (int, string) {
+	if len(updates) == 0 {
+		return 0, "queued"
+	}
+
+	percent := 0
+	currentStage := "queued"
+	for _, stage := range stageOrder {
+		update, started := updates[stage]
+		if !started {
+			break
+		}
+		currentStage = stage
+		if update.CompletedAt != "" {
+			percent = stageWeights[stage]
+		} else {
+			break
+		}
+	}
+
+	return percent, currentStage
+}
+
+// estimateCompletion computes a rolling-average processing_duration across
+// recently processed files of the same contentType and projects it forward
+// from when this file's processing started. File size is not taken into
+// account when picking those files.
+func estimateCompletion(fileMetadata FileMetadata, updates map[string]StageUpdate) string {
+	queuedUpdate, ok := updates["queued"]
+	if !ok {
+		return ""
+	}
+
+	startedAt, err := time.Parse(time.RFC3339, queuedUpdate.StartedAt)
+	if err != nil {
+		return ""
+	}
+
+	avgDuration, ok := averageRecentDuration(fileMetadata.ContentType)
+	if !ok {
+		return ""
+	}
+
+	return startedAt.Add(avgDuration).UTC().Format(time.RFC3339)
+}
+
+// averageRecentDuration queries recently processed files of the same content
+// type and averages their processing_duration, which the batch worker stores
+// as a "X.XX seconds" string.
+func averageRecentDuration(contentType string) (time.Duration, bool) {
+	queryInput := &dynamodb.QueryInput{
+		TableName:              aws.String(metadataTableName),
+		IndexName:              aws.String("StatusIndex"),
+		KeyConditionExpression: aws.String("processing_status = :status"),
+		FilterExpression:       aws.String("content_type = :contentType"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":status":      {S: aws.String("processed")},
+			":contentType": {S: aws.String(contentType)},
+		},
+		Limit:            aws.Int64(recentSamplesForETA),
+		ScanIndexForward: aws.Bool(false),
+	}
+
+	result, err := dynamoClient.Query(queryInput)
+	if err != nil {
+		log.Printf("Failed to query recent files for ETA estimation: %v", err)
+		return 0, false
+	}
+
+	var fileIDs []string
+	for _, item := range result.Items {
+		var fm FileMetadata
+		if err := dynamodbattribute.UnmarshalMap(item, &fm); err != nil {
+			continue
+		}
+		fileIDs = append(fileIDs, fm.FileID)
+	}
+
+	results, err := batchGetProcessingResults(fileIDs)
+	if err != nil {
+		log.Printf("Failed to batch-fetch results for ETA estimation: %v", err)
+	}
+
+	var total time.Duration
+	var count int
+	for _, r := range results {
+		var seconds float64
+		if _, err := fmt.Sscanf(r.ProcessingDuration, "%f seconds", &seconds); err != nil {
+			continue
+		}
+		total += time.Duration(seconds * float64(time.Second))
+		count++
+	}
+
+	if count == 0 {
+		return 0, false
+	}
+
+	return total / time.Duration(count), true
+}
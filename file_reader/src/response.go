@@ -0,0 +1,147 @@
+package main
+
+// response.go shrinks the large extracted_text/textract_analysis payloads
+// this Lambda returns: gzip the body when the client supports it, serve a
+// 304 when the client's cached copy is still fresh, and let callers ask for
+// only the fields they need via ?fields=.
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// parseFieldsParam splits a comma-separated `fields` query parameter into a
+// set of requested top-level JSON field names. A nil/empty set means "no
+// projection, return everything".
+func parseFieldsParam(fieldsParam string) map[string]bool {
+	if fieldsParam == "" {
+		return nil
+	}
+
+	fields := make(map[string]bool)
+	for _, f := range strings.Split(fieldsParam, ",") {
+		if trimmed := strings.TrimSpace(f); trimmed != "" {
+			fields[trimmed] = true
+		}
+	}
+	return fields
+}
+
+// finalizeResponse applies field projection, ETag/If-None-Match caching, and
+// gzip compression to a successful JSON response before it goes out over API
+// Gateway.
+func finalizeResponse(request events.APIGatewayProxyRequest, response events.APIGatewayProxyResponse, fields map[string]bool) (events.APIGatewayProxyResponse, error) {
+	if response.StatusCode != 200 || response.Body == "" {
+		return response, nil
+	}
+
+	body := response.Body
+	if len(fields) > 0 {
+		projected, err := projectFields(body, fields)
+		if err != nil {
+			log.Printf("Failed to project fields: %v", err)
+		} else {
+			body = projected
+		}
+	}
+
+	etag := computeETag(body)
+	response.Headers["ETag"] = etag
+
+	if ifNoneMatch := headerValue(request.Headers, "If-None-Match"); ifNoneMatch != "" && ifNoneMatch == etag {
+		return events.APIGatewayProxyResponse{
+			StatusCode: 304,
+			Headers:    response.Headers,
+			Body:       "",
+		}, nil
+	}
+
+	response.Body = body
+
+	if acceptsGzip(request.Headers) {
+		compressed, err := gzipString(body)
+		if err != nil {
+			log.Printf("Failed to gzip response body: %v", err)
+			return response, nil
+		}
+		response.Headers["Content-Encoding"] = "gzip"
+		response.Body = compressed
+		response.IsBase64Encoded = true
+	}
+
+	return response, nil
+}
+
+// projectFields re-marshals a JSON object (or {files: [...]} envelope)
+// keeping only the requested top-level fields on each file object.
+func projectFields(body string, fields map[string]bool) (string, error) {
+	var envelope map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &envelope); err != nil {
+		return body, err
+	}
+
+	if filesRaw, ok := envelope["files"].([]interface{}); ok {
+		for i, fileRaw := range filesRaw {
+			if fileObj, ok := fileRaw.(map[string]interface{}); ok {
+				filesRaw[i] = projectObject(fileObj, fields)
+			}
+		}
+		envelope["files"] = filesRaw
+	} else {
+		envelope = projectObject(envelope, fields)
+	}
+
+	projected, err := json.Marshal(envelope)
+	if err != nil {
+		return body, err
+	}
+	return string(projected), nil
+}
+
+func projectObject(obj map[string]interface{}, fields map[string]bool) map[string]interface{} {
+	projected := make(map[string]interface{}, len(fields))
+	for field := range fields {
+		if value, ok := obj[field]; ok {
+			projected[field] = value
+		}
+	}
+	return projected
+}
+
+func computeETag(body string) string {
+	sum := md5.Sum([]byte(body))
+	return fmt.Sprintf(`"%s"`, base64.RawURLEncoding.EncodeToString(sum[:]))
+}
+
+func acceptsGzip(headers map[string]string) bool {
+	return strings.Contains(strings.ToLower(headerValue(headers, "Accept-Encoding")), "gzip")
+}
+
+func headerValue(headers map[string]string, key string) string {
+	for k, v := range headers {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return ""
+}
+
+func gzipString(body string) (string, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write([]byte(body)); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
@@ -0,0 +1,223 @@
+package main
+
+// search.go adds optional full-text search/faceted filtering on top of the
+// DynamoDB-backed results list. DynamoDB cannot do full-text search
+// efficiently, so when OPENSEARCH_ENDPOINT is configured, search queries are
+// served from an OpenSearch index (kept up to date by the search-indexer
+// Lambda) and hydrated from DynamoDB; otherwise handleMultipleFilesRequest's
+// Query/Scan path is used as before.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// searchClient is non-nil only when OPENSEARCH_ENDPOINT is configured.
+var (
+	searchClient *http.Client
+	searchIndex  string
+	searchURL    string
+)
+
+func init() {
+	endpoint := os.Getenv("OPENSEARCH_ENDPOINT")
+	if endpoint == "" {
+		return
+	}
+
+	searchIndex = os.Getenv("OPENSEARCH_INDEX")
+	if searchIndex == "" {
+		searchIndex = "ocr-results"
+	}
+
+	searchURL = strings.TrimRight(endpoint, "/") + "/" + searchIndex + "/_search"
+	searchClient = &http.Client{Timeout: 10 * time.Second}
+}
+
+type searchHit struct {
+	Source struct {
+		FileID string `json:"fileId"`
+	} `json:"_source"`
+	Highlight map[string][]string `json:"highlight"`
+}
+
+type searchResponse struct {
+	Hits struct {
+		Total struct {
+			Value int `json:"value"`
+		} `json:"total"`
+		Hits []searchHit `json:"hits"`
+	} `json:"hits"`
+}
+
+// handleSearchRequest queries the OpenSearch index for q/entityType/sentiment/
+// language/dateFrom/dateTo and hydrates matching files from DynamoDB.
+func handleSearchRequest(queryParams map[string]string, limit int64, headers map[string]string) (events.APIGatewayProxyResponse, error) {
+	reqBody, err := buildSearchQuery(queryParams, limit)
+	if err != nil {
+		return createErrorResponse(400, "Bad Request", err.Error())
+	}
+
+	httpResp, err := searchClient.Post(searchURL, "application/json", bytes.NewReader(reqBody))
+	if err != nil {
+		return createErrorResponse(502, "Search Error", fmt.Sprintf("Failed to query search index: %v", err))
+	}
+	defer httpResp.Body.Close()
+
+	var parsed searchResponse
+	if err := json.NewDecoder(httpResp.Body).Decode(&parsed); err != nil {
+		return createErrorResponse(502, "Search Error", fmt.Sprintf("Failed to decode search response: %v", err))
+	}
+
+	highlightsByFileID := make(map[string][]string, len(parsed.Hits.Hits))
+	var fileIDs []string
+	for _, hit := range parsed.Hits.Hits {
+		fileIDs = append(fileIDs, hit.Source.FileID)
+		if snippets, ok := hit.Highlight["extracted_text"]; ok {
+			highlightsByFileID[hit.Source.FileID] = snippets
+		}
+	}
+
+	items, err := hydrateFromDynamo(fileIDs)
+	if err != nil {
+		return createErrorResponse(500, "Database Error", fmt.Sprintf("Failed to hydrate search hits: %v", err))
+	}
+
+	for i := range items {
+		items[i].Highlights = highlightsByFileID[items[i].FileID]
+	}
+
+	responseData := MultiFileResponse{
+		Files:   items,
+		Count:   len(items),
+		HasMore: parsed.Hits.Total.Value > len(items),
+	}
+
+	responseBody, err := json.Marshal(responseData)
+	if err != nil {
+		return createErrorResponse(500, "JSON Error", fmt.Sprintf("Failed to marshal response: %v", err))
+	}
+
+	return events.APIGatewayProxyResponse{StatusCode: 200, Headers: headers, Body: string(responseBody)}, nil
+}
+
+// buildSearchQuery turns the supported query-string parameters into an
+// OpenSearch bool query with a match on q plus term filters for facets.
+func buildSearchQuery(queryParams map[string]string, limit int64) ([]byte, error) {
+	must := []map[string]interface{}{
+		{
+			"match": map[string]interface{}{
+				"extracted_text": queryParams["q"],
+			},
+		},
+	}
+
+	var filter []map[string]interface{}
+	if entityType := queryParams["entityType"]; entityType != "" {
+		filter = append(filter, map[string]interface{}{"term": map[string]interface{}{"entity_types": entityType}})
+	}
+	if sentiment := queryParams["sentiment"]; sentiment != "" {
+		filter = append(filter, map[string]interface{}{"term": map[string]interface{}{"sentiment": sentiment}})
+	}
+	if language := queryParams["language"]; language != "" {
+		filter = append(filter, map[string]interface{}{"term": map[string]interface{}{"language": language}})
+	}
+	if dateFrom, dateTo := queryParams["dateFrom"], queryParams["dateTo"]; dateFrom != "" || dateTo != "" {
+		dateRange := map[string]interface{}{}
+		if dateFrom != "" {
+			dateRange["gte"] = dateFrom
+		}
+		if dateTo != "" {
+			dateRange["lte"] = dateTo
+		}
+		filter = append(filter, map[string]interface{}{"range": map[string]interface{}{"upload_date": dateRange}})
+	}
+
+	query := map[string]interface{}{
+		"size": limit,
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must":   must,
+				"filter": filter,
+			},
+		},
+		"highlight": map[string]interface{}{
+			"fields": map[string]interface{}{
+				"extracted_text": map[string]interface{}{},
+			},
+		},
+	}
+
+	return json.Marshal(query)
+}
+
+// hydrateFromDynamo fetches metadata + results rows for the matched file IDs,
+// reusing the same BatchGetItem helper the plain-list path uses.
+func hydrateFromDynamo(fileIDs []string) ([]SingleFileResponse, error) {
+	if len(fileIDs) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]map[string]*dynamodb.AttributeValue, len(fileIDs))
+	for i, fileID := range fileIDs {
+		keys[i] = map[string]*dynamodb.AttributeValue{"file_id": {S: aws.String(fileID)}}
+	}
+
+	metaResult, err := dynamoClient.BatchGetItem(&dynamodb.BatchGetItemInput{
+		RequestItems: map[string]*dynamodb.KeysAndAttributes{
+			metadataTableName: {Keys: keys},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to batch get metadata: %w", err)
+	}
+
+	processingResults, err := batchGetProcessingResults(fileIDs)
+	if err != nil {
+		log.Printf("Failed to batch-fetch processing results for search hits: %v", err)
+	}
+
+	var items []SingleFileResponse
+	for _, item := range metaResult.Responses[metadataTableName] {
+		var fileMetadata FileMetadata
+		if err := dynamodbattribute.UnmarshalMap(item, &fileMetadata); err != nil {
+			log.Printf("Failed to unmarshal metadata for search hit: %v", err)
+			continue
+		}
+
+		itemData := SingleFileResponse{
+			FileID:           fileMetadata.FileID,
+			FileName:         fileMetadata.FileName,
+			UploadTimestamp:  fileMetadata.UploadTimestamp,
+			ProcessingStatus: fileMetadata.ProcessingStatus,
+			FileSize:         fileMetadata.FileSize,
+			ContentType:      fileMetadata.ContentType,
+			CloudFrontURL:    fmt.Sprintf("https://%s/%s", cloudFrontDomain, fileMetadata.S3Key),
+		}
+
+		if processingResult, ok := processingResults[fileMetadata.FileID]; ok {
+			itemData.ExtractedText = processingResult.ExtractedText
+			itemData.FormattedText = processingResult.FormattedText
+			itemData.TextFormatting = processingResult.TextFormatting
+			itemData.Analysis = processingResult.Analysis
+			itemData.ProcessingDuration = processingResult.ProcessingDuration
+			itemData.ComprehendAnalysis = processingResult.ComprehendAnalysis
+			itemData.TextractAnalysis = processingResult.TextractAnalysis
+		}
+
+		items = append(items, itemData)
+	}
+
+	return items, nil
+}
@@ -22,8 +22,9 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
-	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/google/uuid"
+
+	"github.com/CaringalML/OCR-Processing-Pipeline-Using-AWS-Batch-Serverless-Architecture/pkg/blobstore"
 )
 
 // FileInfo represents file information extracted from multipart form
@@ -47,6 +48,22 @@ type FileMetadata struct {
 	ETag             string `dynamodbav:"etag"`
 	UploadDate       string `dynamodbav:"upload_date"`
 	ExpirationTime   int64  `dynamodbav:"expiration_time"`
+
+	// UploadID, PartsCount, and MultipartComplete are only populated for
+	// files ingested through the /uploads multipart subsystem in
+	// multipart_upload.go; single-shot uploads leave them at their zero
+	// values. The record is still keyed by FileID/UploadTimestamp either
+	// way, so the Batch status-change updater finds it the same way
+	// regardless of which upload path created it.
+	UploadID          string `dynamodbav:"upload_id,omitempty"`
+	PartsCount        int    `dynamodbav:"parts_count,omitempty"`
+	MultipartComplete bool   `dynamodbav:"multipart_complete,omitempty"`
+
+	// StorageProvider records which blobstore.Provider wrote this object
+	// (see pkg/blobstore), so the Batch worker and the status-updater
+	// Lambda know which backend to talk to when they later need to fetch
+	// the object or generate a result-download URL.
+	StorageProvider string `dynamodbav:"storage_provider"`
 }
 
 // UploadResult represents the result of a single file upload
@@ -77,20 +94,34 @@ type SuccessResponse struct {
 	Status        string                   `json:"status,omitempty"`
 }
 
+// DynamoAPI is the subset of *dynamodb.DynamoDB this Lambda calls.
+type DynamoAPI interface {
+	PutItem(*dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error)
+	UpdateItem(*dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error)
+	Query(*dynamodb.QueryInput) (*dynamodb.QueryOutput, error)
+}
+
 var (
-	s3Client      *s3.S3
-	dynamoClient  *dynamodb.DynamoDB
-	bucketName    string
-	dynamoTable   string
+	store        blobstore.Store
+	dynamoClient DynamoAPI
+	bucketName   string
+	dynamoTable  string
 )
 
 func init() {
 	sess := session.Must(session.NewSession())
-	s3Client = s3.New(sess)
 	dynamoClient = dynamodb.New(sess)
-	
+
 	bucketName = os.Getenv("UPLOAD_BUCKET_NAME")
 	dynamoTable = os.Getenv("DYNAMODB_TABLE")
+
+	if bucketName != "" {
+		var err error
+		store, err = blobstore.New(bucketName)
+		if err != nil {
+			log.Fatalf("failed to build blobstore: %v", err)
+		}
+	}
 }
 
 func main() {
@@ -133,6 +164,13 @@ func handleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (
 		}, nil
 	}
 
+	// The /uploads resource tree is the multipart upload subsystem (see
+	// multipart_upload.go); everything else is the original single-shot
+	// form upload handled below.
+	if request.Path == "/uploads" || strings.HasPrefix(request.Path, "/uploads/") {
+		return routeMultipartUpload(ctx, request, headers)
+	}
+
 	// Improved content type detection - check multiple variations
 	contentType := getContentTypeFromHeaders(request.Headers)
 	log.Printf("Detected Content-Type: '%s'", contentType)
@@ -243,7 +281,7 @@ func handleMultipartUpload(ctx context.Context, request events.APIGatewayProxyRe
 			continue
 		}
 
-		result := processSingleFileUpload(file.Filename, file.Content, file.ContentType)
+		result := processSingleFileUpload(ctx, file.Filename, file.Content, file.ContentType)
 		if result.Success {
 			uploadResults = append(uploadResults, result.Data)
 		} else {
@@ -280,33 +318,24 @@ func handleJSONUpload(ctx context.Context, request events.APIGatewayProxyRequest
 	return createErrorResponse(400, "Bad Request", "JSON uploads are not supported. Use multipart/form-data instead.")
 }
 
-func processSingleFileUpload(fileName string, fileBytes []byte, contentType string) UploadResult {
+func processSingleFileUpload(ctx context.Context, fileName string, fileBytes []byte, contentType string) UploadResult {
 	// Generate unique file ID and S3 key
 	fileID := uuid.New().String()
 	timestamp := time.Now().UTC()
 	s3Key := fmt.Sprintf("uploads/%s/%s/%s", timestamp.Format("2006/01/02"), fileID, fileName)
 
-	log.Printf("Uploading file: %s to S3: %s", fileID, s3Key)
-
-	// Upload file to S3
-	putInput := &s3.PutObjectInput{
-		Bucket:      aws.String(bucketName),
-		Key:         aws.String(s3Key),
-		Body:        bytes.NewReader(fileBytes),
-		ContentType: aws.String(contentType),
-		Metadata: map[string]*string{
-			"file_id":          aws.String(fileID),
-			"original_name":    aws.String(fileName),
-			"upload_timestamp": aws.String(timestamp.Format(time.RFC3339)),
-		},
-	}
+	log.Printf("Uploading file: %s to %s store: %s", fileID, store.Provider(), s3Key)
 
-	result, err := s3Client.PutObject(putInput)
+	etag, err := store.Put(ctx, s3Key, bytes.NewReader(fileBytes), map[string]string{
+		"file_id":          fileID,
+		"original_name":    fileName,
+		"upload_timestamp": timestamp.Format(time.RFC3339),
+	})
 	if err != nil {
 		log.Printf("Error uploading file %s: %v", fileName, err)
 		return UploadResult{
 			Success: false,
-			Error:   fmt.Sprintf("S3 upload failed: %v", err),
+			Error:   fmt.Sprintf("Storage upload failed: %v", err),
 		}
 	}
 
@@ -320,9 +349,10 @@ func processSingleFileUpload(fileName string, fileBytes []byte, contentType stri
 		FileSize:         int64(len(fileBytes)),
 		ContentType:      contentType,
 		ProcessingStatus: "uploaded",
-		ETag:             strings.Trim(*result.ETag, "\""),
+		ETag:             etag,
 		UploadDate:       timestamp.Format("2006-01-02"),
 		ExpirationTime:   timestamp.Unix() + 365*24*60*60, // 1 year TTL
+		StorageProvider:  string(store.Provider()),
 	}
 
 	item, err := dynamodbattribute.MarshalMap(metadata)
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/google/uuid"
+
+	"github.com/CaringalML/OCR-Processing-Pipeline-Using-AWS-Batch-Serverless-Architecture/pkg/blobstore"
+)
+
+// multipart_upload.go adds a four-endpoint multipart upload subsystem
+// (initiate, upload part, complete, abort), modeled on the S3 REST API, so large scanned PDFs aren't capped by API
+// Gateway's payload limit the way the single-shot handleMultipartUpload
+// flow is. The client-facing "upload_id" returned by initiate is this
+// pipeline's own FileID, not S3's UploadId - the real S3 UploadId is stored
+// on the DynamoDB record and resolved server-side, so every later call can
+// be looked up with the same file_id Query the rest of the pipeline
+// already uses instead of a new GSI.
+
+// InitiateUploadRequest is the POST /uploads body.
+type InitiateUploadRequest struct {
+	FileName    string `json:"fileName"`
+	ContentType string `json:"contentType"`
+}
+
+// InitiateUploadResponse is returned from POST /uploads.
+type InitiateUploadResponse struct {
+	UploadID  string `json:"uploadId"`
+	Bucket    string `json:"bucket"`
+	Timestamp string `json:"timestamp"`
+}
+
+// UploadPartResponse is returned from PUT /uploads/{upload_id}/parts/{part_number}.
+type UploadPartResponse struct {
+	PartNumber int64  `json:"partNumber"`
+	ETag       string `json:"etag"`
+}
+
+// CompleteUploadRequest is the POST /uploads/{upload_id}/complete body.
+type CompleteUploadRequest struct {
+	Parts []CompletedPartInput `json:"parts"`
+}
+
+// CompletedPartInput is one entry of the ordered part list the client
+// collected from each UploadPartResponse.
+type CompletedPartInput struct {
+	PartNumber int64  `json:"partNumber"`
+	ETag       string `json:"etag"`
+}
+
+// CompleteUploadResponse is returned from POST /uploads/{upload_id}/complete.
+type CompleteUploadResponse struct {
+	FileID    string `json:"fileId"`
+	S3Key     string `json:"s3Key"`
+	Bucket    string `json:"bucket"`
+	ETag      string `json:"etag"`
+	Status    string `json:"status"`
+	Timestamp string `json:"timestamp"`
+}
+
+// routeMultipartUpload dispatches the /uploads resource tree by HTTPMethod
+// and path shape. There are only five routes (the four above plus
+// presigned_upload.go's POST /uploads/presign), so a small switch reads
+// easier than pulling in a router dependency for this one Lambda.
+func routeMultipartUpload(ctx context.Context, request events.APIGatewayProxyRequest, headers map[string]string) (events.APIGatewayProxyResponse, error) {
+	segments := strings.Split(strings.Trim(request.Path, "/"), "/")
+
+	switch {
+	case request.HTTPMethod == "POST" && len(segments) == 2 && segments[1] == "presign":
+		return handleInitiatePresignedUpload(ctx, request, headers)
+	case request.HTTPMethod == "POST" && len(segments) == 1:
+		return handleInitiateMultipartUpload(ctx, request, headers)
+	case request.HTTPMethod == "PUT" && len(segments) == 4 && segments[2] == "parts":
+		return handleUploadPart(ctx, segments[1], segments[3], request, headers)
+	case request.HTTPMethod == "POST" && len(segments) == 3 && segments[2] == "complete":
+		return handleCompleteMultipartUpload(ctx, segments[1], request, headers)
+	case request.HTTPMethod == "DELETE" && len(segments) == 2:
+		return handleAbortMultipartUpload(ctx, segments[1], headers)
+	default:
+		return createErrorResponse(404, "Not Found", fmt.Sprintf("No multipart upload route for %s %s", request.HTTPMethod, request.Path))
+	}
+}
+
+// handleInitiateMultipartUpload implements POST /uploads.
+func handleInitiateMultipartUpload(ctx context.Context, request events.APIGatewayProxyRequest, headers map[string]string) (events.APIGatewayProxyResponse, error) {
+	var body InitiateUploadRequest
+	if err := json.Unmarshal([]byte(request.Body), &body); err != nil {
+		return createErrorResponse(400, "Bad Request", fmt.Sprintf("Invalid request body: %v", err))
+	}
+	if body.FileName == "" {
+		return createErrorResponse(400, "Bad Request", "fileName is required")
+	}
+	contentType := body.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	fileID := uuid.New().String()
+	timestamp := time.Now().UTC()
+	s3Key := fmt.Sprintf("uploads/%s/%s/%s", timestamp.Format("2006/01/02"), fileID, body.FileName)
+
+	uploadID, err := store.InitiateMultipart(ctx, s3Key, map[string]string{
+		"file_id":          fileID,
+		"original_name":    body.FileName,
+		"upload_timestamp": timestamp.Format(time.RFC3339),
+	})
+	if err != nil {
+		log.Printf("Error initiating multipart upload for %s: %v", body.FileName, err)
+		return createErrorResponse(500, "Internal Error", fmt.Sprintf("Failed to initiate multipart upload: %v", err))
+	}
+
+	metadata := FileMetadata{
+		FileID:           fileID,
+		UploadTimestamp:  timestamp.Format(time.RFC3339),
+		BucketName:       bucketName,
+		S3Key:            s3Key,
+		FileName:         body.FileName,
+		ContentType:      contentType,
+		ProcessingStatus: "initiated",
+		UploadDate:       timestamp.Format("2006-01-02"),
+		ExpirationTime:   timestamp.Unix() + 365*24*60*60,
+		UploadID:         uploadID,
+		StorageProvider:  string(store.Provider()),
+	}
+
+	item, err := dynamodbattribute.MarshalMap(metadata)
+	if err != nil {
+		log.Printf("Error marshaling multipart session for %s: %v", fileID, err)
+		return createErrorResponse(500, "Internal Error", fmt.Sprintf("DynamoDB marshal failed: %v", err))
+	}
+
+	if _, err := dynamoClient.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(dynamoTable),
+		Item:      item,
+	}); err != nil {
+		log.Printf("Error storing multipart session for %s: %v", fileID, err)
+		return createErrorResponse(500, "Internal Error", fmt.Sprintf("DynamoDB put failed: %v", err))
+	}
+
+	response := InitiateUploadResponse{
+		UploadID:  fileID,
+		Bucket:    bucketName,
+		Timestamp: timestamp.Format(time.RFC3339),
+	}
+	responseBody, _ := json.Marshal(response)
+	return events.APIGatewayProxyResponse{StatusCode: 200, Headers: headers, Body: string(responseBody)}, nil
+}
+
+// handleUploadPart implements PUT /uploads/{upload_id}/parts/{part_number}.
+func handleUploadPart(ctx context.Context, uploadID, partNumberRaw string, request events.APIGatewayProxyRequest, headers map[string]string) (events.APIGatewayProxyResponse, error) {
+	partNumber, err := strconv.ParseInt(partNumberRaw, 10, 64)
+	if err != nil || partNumber < 1 {
+		return createErrorResponse(400, "Bad Request", "part_number must be a positive integer")
+	}
+
+	session, err := findMultipartSession(uploadID)
+	if err != nil {
+		return createErrorResponse(404, "Not Found", err.Error())
+	}
+
+	var body []byte
+	if request.IsBase64Encoded {
+		body, err = base64.StdEncoding.DecodeString(request.Body)
+		if err != nil {
+			return createErrorResponse(400, "Bad Request", "Invalid base64 encoding")
+		}
+	} else {
+		body = []byte(request.Body)
+	}
+	if len(body) == 0 {
+		return createErrorResponse(400, "Bad Request", "Part body is empty")
+	}
+
+	etag, err := store.UploadPart(ctx, session.S3Key, session.UploadID, partNumber, strings.NewReader(string(body)))
+	if err != nil {
+		log.Printf("Error uploading part %d for upload %s: %v", partNumber, uploadID, err)
+		return createErrorResponse(500, "Internal Error", fmt.Sprintf("Failed to upload part: %v", err))
+	}
+
+	if _, err := dynamoClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(dynamoTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"file_id":          {S: aws.String(session.FileID)},
+			"upload_timestamp": {S: aws.String(session.UploadTimestamp)},
+		},
+		UpdateExpression: aws.String("ADD parts_count :one"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":one": {N: aws.String("1")},
+		},
+	}); err != nil {
+		log.Printf("Warning: failed to bump parts_count for upload %s: %v", uploadID, err)
+	}
+
+	response := UploadPartResponse{
+		PartNumber: partNumber,
+		ETag:       etag,
+	}
+	responseBody, _ := json.Marshal(response)
+	return events.APIGatewayProxyResponse{StatusCode: 200, Headers: headers, Body: string(responseBody)}, nil
+}
+
+// handleCompleteMultipartUpload implements POST /uploads/{upload_id}/complete.
+func handleCompleteMultipartUpload(ctx context.Context, uploadID string, request events.APIGatewayProxyRequest, headers map[string]string) (events.APIGatewayProxyResponse, error) {
+	var body CompleteUploadRequest
+	if err := json.Unmarshal([]byte(request.Body), &body); err != nil {
+		return createErrorResponse(400, "Bad Request", fmt.Sprintf("Invalid request body: %v", err))
+	}
+	if len(body.Parts) == 0 {
+		return createErrorResponse(400, "Bad Request", "parts must not be empty")
+	}
+
+	session, err := findMultipartSession(uploadID)
+	if err != nil {
+		return createErrorResponse(404, "Not Found", err.Error())
+	}
+
+	completedParts := make([]blobstore.CompletedPart, len(body.Parts))
+	for i, part := range body.Parts {
+		completedParts[i] = blobstore.CompletedPart{PartNumber: part.PartNumber, ETag: part.ETag}
+	}
+
+	etag, err := store.Complete(ctx, session.S3Key, session.UploadID, completedParts)
+	if err != nil {
+		log.Printf("Error completing multipart upload %s: %v", uploadID, err)
+		return createErrorResponse(500, "Internal Error", fmt.Sprintf("Failed to complete multipart upload: %v", err))
+	}
+
+	// file_size holds the object's length in bytes, as on every other upload
+	// path, so it is read back from the assembled object.
+	var size int64
+	if head, err := store.Head(ctx, session.S3Key); err != nil {
+		log.Printf("Warning: failed to read size of completed upload %s: %v", uploadID, err)
+	} else {
+		size = head.ContentLength
+	}
+
+	// CompleteMultipartUpload itself fires the same S3
+	// ObjectCreated:CompleteMultipartUpload event that single-shot
+	// PutObject uploads do, so the existing EventBridge rule -> sqs_processor
+	// path picks this file up and submits the Batch job without this
+	// Lambda calling Batch directly.
+	if _, err := dynamoClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(dynamoTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"file_id":          {S: aws.String(session.FileID)},
+			"upload_timestamp": {S: aws.String(session.UploadTimestamp)},
+		},
+		UpdateExpression: aws.String("SET processing_status = :status, multipart_complete = :complete, etag = :etag, file_size = :size"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":status":   {S: aws.String("uploaded")},
+			":complete": {BOOL: aws.Bool(true)},
+			":etag":     {S: aws.String(etag)},
+			":size":     {N: aws.String(fmt.Sprintf("%d", size))},
+		},
+	}); err != nil {
+		log.Printf("Error marking multipart upload %s complete: %v", uploadID, err)
+		return createErrorResponse(500, "Internal Error", fmt.Sprintf("Failed to update file status: %v", err))
+	}
+
+	response := CompleteUploadResponse{
+		FileID:    session.FileID,
+		S3Key:     session.S3Key,
+		Bucket:    session.BucketName,
+		ETag:      etag,
+		Status:    "uploaded",
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+	responseBody, _ := json.Marshal(response)
+	return events.APIGatewayProxyResponse{StatusCode: 200, Headers: headers, Body: string(responseBody)}, nil
+}
+
+// handleAbortMultipartUpload implements DELETE /uploads/{upload_id}.
+func handleAbortMultipartUpload(ctx context.Context, uploadID string, headers map[string]string) (events.APIGatewayProxyResponse, error) {
+	session, err := findMultipartSession(uploadID)
+	if err != nil {
+		return createErrorResponse(404, "Not Found", err.Error())
+	}
+
+	if err := store.Abort(ctx, session.S3Key, session.UploadID); err != nil {
+		log.Printf("Error aborting multipart upload %s: %v", uploadID, err)
+		return createErrorResponse(500, "Internal Error", fmt.Sprintf("Failed to abort multipart upload: %v", err))
+	}
+
+	if _, err := dynamoClient.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(dynamoTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"file_id":          {S: aws.String(session.FileID)},
+			"upload_timestamp": {S: aws.String(session.UploadTimestamp)},
+		},
+		UpdateExpression: aws.String("SET processing_status = :status"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":status": {S: aws.String("aborted")},
+		},
+	}); err != nil {
+		log.Printf("Warning: failed to mark aborted upload %s as aborted: %v", uploadID, err)
+	}
+
+	responseBody, _ := json.Marshal(map[string]interface{}{
+		"success":   true,
+		"uploadId":  uploadID,
+		"status":    "aborted",
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+	})
+	return events.APIGatewayProxyResponse{StatusCode: 200, Headers: headers, Body: string(responseBody)}, nil
+}
+
+// multipartSession is the subset of a FileMetadata record needed to act on
+// an in-progress multipart upload.
+type multipartSession struct {
+	FileID          string
+	UploadTimestamp string
+	BucketName      string
+	S3Key           string
+	UploadID        string
+}
+
+// findMultipartSession looks up the multipart session for uploadID (the
+// pipeline's own file_id) via the same file_id Query the rest of the
+// codebase uses, rather than scanning for the real S3 UploadId.
+func findMultipartSession(uploadID string) (*multipartSession, error) {
+	queryResult, err := dynamoClient.Query(&dynamodb.QueryInput{
+		TableName:              aws.String(dynamoTable),
+		KeyConditionExpression: aws.String("file_id = :fileId"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":fileId": {S: aws.String(uploadID)},
+		},
+		Limit: aws.Int64(1),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query upload session: %v", err)
+	}
+	if len(queryResult.Items) == 0 {
+		return nil, fmt.Errorf("upload %s not found", uploadID)
+	}
+
+	var metadata FileMetadata
+	if err := dynamodbattribute.UnmarshalMap(queryResult.Items[0], &metadata); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal upload session: %v", err)
+	}
+	if metadata.UploadID == "" {
+		return nil, fmt.Errorf("upload %s is not a multipart session", uploadID)
+	}
+
+	return &multipartSession{
+		FileID:          metadata.FileID,
+		UploadTimestamp: metadata.UploadTimestamp,
+		BucketName:      metadata.BucketName,
+		S3Key:           metadata.S3Key,
+		UploadID:        metadata.UploadID,
+	}, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/CaringalML/OCR-Processing-Pipeline-Using-AWS-Batch-Serverless-Architecture/internal/awsfake"
+)
+
+func setupFakeClients() {
+	store = awsfake.NewBlobStore()
+	dynamoClient = awsfake.NewDynamoStore()
+	bucketName = "test-bucket"
+	dynamoTable = "file-metadata"
+}
+
+func TestMultipartUploadLifecycle(t *testing.T) {
+	setupFakeClients()
+	ctx := context.Background()
+
+	initiateBody, _ := json.Marshal(InitiateUploadRequest{FileName: "scan.pdf", ContentType: "application/pdf"})
+	initiateResp, err := handleRequest(ctx, events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Path:       "/uploads",
+		Body:       string(initiateBody),
+	})
+	if err != nil || initiateResp.StatusCode != 200 {
+		t.Fatalf("initiate failed: status=%d err=%v body=%s", initiateResp.StatusCode, err, initiateResp.Body)
+	}
+	var initiated InitiateUploadResponse
+	if err := json.Unmarshal([]byte(initiateResp.Body), &initiated); err != nil {
+		t.Fatalf("unmarshal initiate response: %v", err)
+	}
+
+	partResp, err := handleRequest(ctx, events.APIGatewayProxyRequest{
+		HTTPMethod: "PUT",
+		Path:       "/uploads/" + initiated.UploadID + "/parts/1",
+		Body:       "part-one-bytes",
+	})
+	if err != nil || partResp.StatusCode != 200 {
+		t.Fatalf("upload part failed: status=%d err=%v body=%s", partResp.StatusCode, err, partResp.Body)
+	}
+	var part UploadPartResponse
+	if err := json.Unmarshal([]byte(partResp.Body), &part); err != nil {
+		t.Fatalf("unmarshal part response: %v", err)
+	}
+
+	completeBody, _ := json.Marshal(CompleteUploadRequest{Parts: []CompletedPartInput{{PartNumber: 1, ETag: part.ETag}}})
+	completeResp, err := handleRequest(ctx, events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Path:       "/uploads/" + initiated.UploadID + "/complete",
+		Body:       string(completeBody),
+	})
+	if err != nil || completeResp.StatusCode != 200 {
+		t.Fatalf("complete failed: status=%d err=%v body=%s", completeResp.StatusCode, err, completeResp.Body)
+	}
+	var completed CompleteUploadResponse
+	if err := json.Unmarshal([]byte(completeResp.Body), &completed); err != nil {
+		t.Fatalf("unmarshal complete response: %v", err)
+	}
+	if completed.Status != "uploaded" {
+		t.Fatalf("expected status=uploaded, got %q", completed.Status)
+	}
+}
+
+func TestInitiatePresignedUploadReturnsURL(t *testing.T) {
+	setupFakeClients()
+	ctx := context.Background()
+
+	body, _ := json.Marshal(PresignUploadRequest{FileName: "scan.pdf", ContentType: "application/pdf", Size: 1024})
+	resp, err := handleRequest(ctx, events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Path:       "/uploads/presign",
+		Body:       string(body),
+	})
+	if err != nil || resp.StatusCode != 200 {
+		t.Fatalf("presign failed: status=%d err=%v body=%s", resp.StatusCode, err, resp.Body)
+	}
+	var presigned PresignUploadResponse
+	if err := json.Unmarshal([]byte(resp.Body), &presigned); err != nil {
+		t.Fatalf("unmarshal presign response: %v", err)
+	}
+	if presigned.UploadURL == "" {
+		t.Fatalf("expected a non-empty upload URL")
+	}
+}
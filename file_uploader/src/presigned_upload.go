@@ -0,0 +1,121 @@
+package main
+
+// presigned_upload.go adds a second large-file upload path alongside
+// multipart_upload.go: instead of the client streaming bytes through API
+// Gateway/Lambda at all, it PUTs directly to S3 against a presigned URL.
+// This Lambda only ever sees the request that asks for that URL; the S3
+// ObjectCreated event the direct PUT fires is picked up by
+// upload_finalizer, which patches the real FileSize/ETag onto the row
+// written here and flips processing_status to "uploaded" - the same
+// EventBridge rule that already feeds sqs_processor's Batch job
+// submission for every other upload path.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/google/uuid"
+
+	"github.com/CaringalML/OCR-Processing-Pipeline-Using-AWS-Batch-Serverless-Architecture/pkg/blobstore"
+)
+
+// presignedUploadURLExpiry bounds how long the client has to PUT bytes
+// before the presigned URL stops working.
+const presignedUploadURLExpiry = 15 * time.Minute
+
+// maxPresignedUploadSize is a generous ceiling for scanned documents; it
+// exists to reject obviously-wrong client-declared sizes up front rather
+// than to police the real upload (upload_finalizer reconciles FileSize
+// against the real object once it lands).
+const maxPresignedUploadSize = 500 * 1024 * 1024
+
+// PresignUploadRequest is the POST /uploads/presign body.
+type PresignUploadRequest struct {
+	FileName    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+}
+
+// PresignUploadResponse is returned from POST /uploads/presign.
+type PresignUploadResponse struct {
+	FileID    string `json:"fileId"`
+	UploadURL string `json:"uploadUrl"`
+	Bucket    string `json:"bucket"`
+	S3Key     string `json:"s3Key"`
+	ExpiresIn int    `json:"expiresIn"`
+	Timestamp string `json:"timestamp"`
+}
+
+// handleInitiatePresignedUpload implements POST /uploads/presign.
+func handleInitiatePresignedUpload(ctx context.Context, request events.APIGatewayProxyRequest, headers map[string]string) (events.APIGatewayProxyResponse, error) {
+	var body PresignUploadRequest
+	if err := json.Unmarshal([]byte(request.Body), &body); err != nil {
+		return createErrorResponse(400, "Bad Request", fmt.Sprintf("Invalid request body: %v", err))
+	}
+	if body.FileName == "" {
+		return createErrorResponse(400, "Bad Request", "filename is required")
+	}
+	if body.Size <= 0 || body.Size > maxPresignedUploadSize {
+		return createErrorResponse(400, "Bad Request", fmt.Sprintf("size must be between 1 and %d bytes", maxPresignedUploadSize))
+	}
+	contentType := body.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	fileID := uuid.New().String()
+	timestamp := time.Now().UTC()
+	s3Key := fmt.Sprintf("uploads/%s/%s/%s", timestamp.Format("2006/01/02"), fileID, body.FileName)
+
+	uploadURL, err := store.Presign(ctx, blobstore.PresignPut, s3Key, presignedUploadURLExpiry)
+	if err != nil {
+		log.Printf("Error presigning upload URL for %s: %v", body.FileName, err)
+		return createErrorResponse(500, "Internal Error", fmt.Sprintf("Failed to presign upload URL: %v", err))
+	}
+
+	metadata := FileMetadata{
+		FileID:           fileID,
+		UploadTimestamp:  timestamp.Format(time.RFC3339),
+		BucketName:       bucketName,
+		S3Key:            s3Key,
+		FileName:         body.FileName,
+		FileSize:         body.Size,
+		ContentType:      contentType,
+		ProcessingStatus: "uploaded-pending",
+		UploadDate:       timestamp.Format("2006-01-02"),
+		ExpirationTime:   timestamp.Unix() + 365*24*60*60,
+		StorageProvider:  string(store.Provider()),
+	}
+
+	item, err := dynamodbattribute.MarshalMap(metadata)
+	if err != nil {
+		log.Printf("Error marshaling presigned upload session for %s: %v", fileID, err)
+		return createErrorResponse(500, "Internal Error", fmt.Sprintf("DynamoDB marshal failed: %v", err))
+	}
+
+	if _, err := dynamoClient.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(dynamoTable),
+		Item:      item,
+	}); err != nil {
+		log.Printf("Error storing presigned upload session for %s: %v", fileID, err)
+		return createErrorResponse(500, "Internal Error", fmt.Sprintf("DynamoDB put failed: %v", err))
+	}
+
+	response := PresignUploadResponse{
+		FileID:    fileID,
+		UploadURL: uploadURL,
+		Bucket:    bucketName,
+		S3Key:     s3Key,
+		ExpiresIn: int(presignedUploadURLExpiry.Seconds()),
+		Timestamp: timestamp.Format(time.RFC3339),
+	}
+	responseBody, _ := json.Marshal(response)
+	return events.APIGatewayProxyResponse{StatusCode: 200, Headers: headers, Body: string(responseBody)}, nil
+}
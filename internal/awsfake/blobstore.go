@@ -0,0 +1,172 @@
+package awsfake
+
+// blobstore.go is an in-memory blobstore.Store, mirroring S3Store's
+// semantics (real MD5 ETags, part assembly on Complete) but against the
+// narrower, bucket-scoped Store interface file_uploader's production code
+// talks to via pkg/blobstore.
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CaringalML/OCR-Processing-Pipeline-Using-AWS-Batch-Serverless-Architecture/pkg/blobstore"
+)
+
+// BlobStore is an in-memory blobstore.Store for tests.
+type BlobStore struct {
+	mu       sync.Mutex
+	objects  map[string]*blobObject
+	uploads  map[string]*blobUpload
+	nextID   int
+	provider blobstore.Provider
+}
+
+type blobObject struct {
+	body        []byte
+	contentType string
+}
+
+type blobUpload struct {
+	key   string
+	parts map[int64][]byte
+}
+
+// NewBlobStore returns an empty store reporting blobstore.ProviderS3, which
+// is all any test needs since provider-specific behavior lives in pkg/blobstore
+// itself, not in the callers this fake stands in for.
+func NewBlobStore() *BlobStore {
+	return &BlobStore{
+		objects:  make(map[string]*blobObject),
+		uploads:  make(map[string]*blobUpload),
+		provider: blobstore.ProviderS3,
+	}
+}
+
+func (s *BlobStore) Provider() blobstore.Provider { return s.provider }
+
+func md5Hex(data []byte) string {
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *BlobStore) Put(ctx context.Context, key string, body io.Reader, meta map[string]string) (string, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = &blobObject{body: data}
+	return md5Hex(data), nil
+}
+
+func (s *BlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("awsfake: not found: %s", key)
+	}
+	return io.NopCloser(bytes.NewReader(obj.body)), nil
+}
+
+func (s *BlobStore) Presign(ctx context.Context, op blobstore.PresignOp, key string, ttl time.Duration) (string, error) {
+	return fmt.Sprintf("https://fake-blobstore.test/%s?op=%s&expires=%d", key, op, int64(ttl.Seconds())), nil
+}
+
+func (s *BlobStore) InitiateMultipart(ctx context.Context, key string, meta map[string]string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	uploadID := fmt.Sprintf("fake-upload-%d", s.nextID)
+	s.uploads[uploadID] = &blobUpload{key: key, parts: make(map[int64][]byte)}
+	return uploadID, nil
+}
+
+func (s *BlobStore) UploadPart(ctx context.Context, key, uploadID string, partNumber int64, body io.Reader) (string, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	upload, ok := s.uploads[uploadID]
+	if !ok {
+		return "", fmt.Errorf("awsfake: no such upload %s", uploadID)
+	}
+	upload.parts[partNumber] = data
+	return md5Hex(data), nil
+}
+
+func (s *BlobStore) Complete(ctx context.Context, key, uploadID string, parts []blobstore.CompletedPart) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	upload, ok := s.uploads[uploadID]
+	if !ok {
+		return "", fmt.Errorf("awsfake: no such upload %s", uploadID)
+	}
+
+	sorted := append([]blobstore.CompletedPart(nil), parts...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].PartNumber < sorted[j].PartNumber })
+
+	var body []byte
+	for _, part := range sorted {
+		content, ok := upload.parts[part.PartNumber]
+		if !ok {
+			return "", fmt.Errorf("awsfake: part %d was never uploaded", part.PartNumber)
+		}
+		body = append(body, content...)
+	}
+
+	s.objects[key] = &blobObject{body: body}
+	delete(s.uploads, uploadID)
+	return md5Hex(body), nil
+}
+
+func (s *BlobStore) Abort(ctx context.Context, key, uploadID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.uploads, uploadID)
+	return nil
+}
+
+func (s *BlobStore) Head(ctx context.Context, key string) (blobstore.Head, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	obj, ok := s.objects[key]
+	if !ok {
+		return blobstore.Head{}, fmt.Errorf("awsfake: not found: %s", key)
+	}
+	return blobstore.Head{ContentLength: int64(len(obj.body)), ContentType: obj.contentType, ETag: md5Hex(obj.body)}, nil
+}
+
+func (s *BlobStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, key)
+	return nil
+}
+
+func (s *BlobStore) List(ctx context.Context, prefix string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keys []string
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
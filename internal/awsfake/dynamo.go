@@ -0,0 +1,216 @@
+package awsfake
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// DynamoStore is an in-memory substitute for the single-table, file_id/
+// upload_timestamp-keyed DynamoDB table every Lambda in this repo talks to.
+// It supports only what those Lambdas actually use: PutItem, UpdateItem
+// with a small subset of ConditionExpression/UpdateExpression grammar
+// (attribute_exists/attribute_not_exists, "field = :value" equality, SET
+// and ADD clauses joined with "AND"/","), and Query by partition key.
+type DynamoStore struct {
+	mu    sync.Mutex
+	items map[string]map[string]*dynamodb.AttributeValue
+}
+
+// NewDynamoStore returns an empty table.
+func NewDynamoStore() *DynamoStore {
+	return &DynamoStore{items: make(map[string]map[string]*dynamodb.AttributeValue)}
+}
+
+func itemKey(item map[string]*dynamodb.AttributeValue) string {
+	return aws.StringValue(item["file_id"].S) + "/" + aws.StringValue(item["upload_timestamp"].S)
+}
+
+// PutItem stores item, keyed by its file_id/upload_timestamp pair.
+func (d *DynamoStore) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.items[itemKey(input.Item)] = cloneItem(input.Item)
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+// UpdateItem applies input's UpdateExpression to the keyed item, first
+// creating it if absent (matching real DynamoDB's upsert-by-default
+// behavior), after checking ConditionExpression if one is set.
+func (d *DynamoStore) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := aws.StringValue(input.Key["file_id"].S) + "/" + aws.StringValue(input.Key["upload_timestamp"].S)
+	item, exists := d.items[key]
+
+	if input.ConditionExpression != nil {
+		if !evalConditionExpression(aws.StringValue(input.ConditionExpression), item, exists, input.ExpressionAttributeValues) {
+			return nil, &fakeAWSError{code: dynamodb.ErrCodeConditionalCheckFailedException, message: "The conditional request failed"}
+		}
+	}
+
+	if !exists {
+		item = map[string]*dynamodb.AttributeValue{}
+		for k, v := range input.Key {
+			item[k] = v
+		}
+	}
+
+	applyUpdateExpression(aws.StringValue(input.UpdateExpression), item, input.ExpressionAttributeValues)
+	d.items[key] = item
+
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+// Query supports exactly the one shape every Lambda in this repo issues:
+// KeyConditionExpression "file_id = :placeholder", optionally Limit-ed.
+func (d *DynamoStore) Query(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	fileID := findEqualityValue(aws.StringValue(input.KeyConditionExpression), "file_id", input.ExpressionAttributeValues)
+	if fileID == "" {
+		return nil, fmt.Errorf("awsfake: Query only supports a file_id equality KeyConditionExpression, got %q", aws.StringValue(input.KeyConditionExpression))
+	}
+
+	var matches []map[string]*dynamodb.AttributeValue
+	for _, item := range d.items {
+		if aws.StringValue(item["file_id"].S) == fileID {
+			matches = append(matches, cloneItem(item))
+		}
+	}
+
+	if input.Limit != nil && int64(len(matches)) > aws.Int64Value(input.Limit) {
+		matches = matches[:aws.Int64Value(input.Limit)]
+	}
+
+	return &dynamodb.QueryOutput{Items: matches, Count: aws.Int64(int64(len(matches)))}, nil
+}
+
+func cloneItem(item map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	out := make(map[string]*dynamodb.AttributeValue, len(item))
+	for k, v := range item {
+		out[k] = v
+	}
+	return out
+}
+
+// findEqualityValue extracts the value bound to "field = :placeholder" in
+// expr, resolving :placeholder against values. It's deliberately narrow -
+// every KeyConditionExpression in this codebase is exactly this shape.
+func findEqualityValue(expr, field string, values map[string]*dynamodb.AttributeValue) string {
+	parts := strings.SplitN(expr, "=", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) != field {
+		return ""
+	}
+	placeholder := strings.TrimSpace(parts[1])
+	av, ok := values[placeholder]
+	if !ok {
+		return ""
+	}
+	return aws.StringValue(av.S)
+}
+
+// evalConditionExpression evaluates the small set of ConditionExpression
+// clauses this repo's Lambdas write, joined with "AND". Unsupported clauses
+// fail closed (return false) rather than silently passing.
+func evalConditionExpression(expr string, item map[string]*dynamodb.AttributeValue, exists bool, values map[string]*dynamodb.AttributeValue) bool {
+	clauses := strings.Split(expr, " AND ")
+	for _, clause := range clauses {
+		clause = strings.TrimSpace(clause)
+		switch {
+		case strings.HasPrefix(clause, "attribute_exists("):
+			field := strings.TrimSuffix(strings.TrimPrefix(clause, "attribute_exists("), ")")
+			if !exists {
+				return false
+			}
+			if _, ok := item[field]; !ok {
+				return false
+			}
+		case strings.HasPrefix(clause, "attribute_not_exists("):
+			field := strings.TrimSuffix(strings.TrimPrefix(clause, "attribute_not_exists("), ")")
+			if exists {
+				if _, ok := item[field]; ok {
+					return false
+				}
+			}
+		case strings.Contains(clause, "="):
+			parts := strings.SplitN(clause, "=", 2)
+			field := strings.TrimSpace(parts[0])
+			placeholder := strings.TrimSpace(parts[1])
+			want, ok := values[placeholder]
+			if !ok {
+				return false
+			}
+			if !exists {
+				return false
+			}
+			got, ok := item[field]
+			if !ok || aws.StringValue(got.S) != aws.StringValue(want.S) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// applyUpdateExpression handles the SET and ADD clauses this repo's
+// UpdateItem calls use. SET assigns values straight from
+// ExpressionAttributeValues; ADD is only ever used here to increment a
+// numeric counter by one.
+func applyUpdateExpression(expr string, item map[string]*dynamodb.AttributeValue, values map[string]*dynamodb.AttributeValue) {
+	expr = strings.TrimSpace(expr)
+	for _, keyword := range []string{"SET", "ADD"} {
+		idx := strings.Index(expr, keyword+" ")
+		if idx == -1 {
+			continue
+		}
+		rest := expr[idx+len(keyword)+1:]
+		for _, nextKeyword := range []string{"SET ", "ADD ", "REMOVE ", "DELETE "} {
+			if nextIdx := strings.Index(rest, " "+strings.TrimSpace(nextKeyword)); nextIdx != -1 && nextKeyword != keyword+" " {
+				rest = rest[:nextIdx]
+			}
+		}
+
+		for _, clause := range strings.Split(rest, ",") {
+			clause = strings.TrimSpace(clause)
+			if clause == "" {
+				continue
+			}
+			parts := strings.SplitN(clause, "=", 2)
+			if keyword == "ADD" {
+				// ADD clauses read "field :value", with no "=".
+				parts = strings.Fields(clause)
+			}
+			if len(parts) != 2 {
+				continue
+			}
+			field := strings.TrimSpace(parts[0])
+			placeholder := strings.TrimSpace(parts[1])
+			value, ok := values[placeholder]
+			if !ok {
+				continue
+			}
+
+			if keyword == "ADD" {
+				current := int64(0)
+				if existing, ok := item[field]; ok && existing.N != nil {
+					fmt.Sscanf(aws.StringValue(existing.N), "%d", &current)
+				}
+				delta := int64(0)
+				if value.N != nil {
+					fmt.Sscanf(aws.StringValue(value.N), "%d", &delta)
+				}
+				item[field] = &dynamodb.AttributeValue{N: aws.String(fmt.Sprintf("%d", current+delta))}
+			} else {
+				item[field] = value
+			}
+		}
+	}
+}
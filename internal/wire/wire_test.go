@@ -0,0 +1,120 @@
+package wire
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"testing"
+)
+
+type testPayload struct {
+	FileID string
+	Count  int
+}
+
+func TestSetDataGetDataRoundTrip(t *testing.T) {
+	var e Envelope
+	want := testPayload{FileID: "abc", Count: 3}
+	if err := e.SetData(want); err != nil {
+		t.Fatalf("SetData: %v", err)
+	}
+	if e.Version != VersionGob {
+		t.Fatalf("expected Version %d, got %d", VersionGob, e.Version)
+	}
+
+	var got testPayload
+	if err := e.GetData(&got); err != nil {
+		t.Fatalf("GetData: %v", err)
+	}
+	if got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	var e Envelope
+	if err := e.SetData(testPayload{FileID: "xyz", Count: 7}); err != nil {
+		t.Fatalf("SetData: %v", err)
+	}
+
+	data, err := Encode(e)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if !IsEnvelope(data) {
+		t.Fatal("expected Encode's output to satisfy IsEnvelope")
+	}
+
+	decoded, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	var got testPayload
+	if err := decoded.GetData(&got); err != nil {
+		t.Fatalf("GetData: %v", err)
+	}
+	if got.FileID != "xyz" || got.Count != 7 {
+		t.Fatalf("unexpected payload after round trip: %+v", got)
+	}
+}
+
+func TestIsEnvelopeRejectsLegacyJSON(t *testing.T) {
+	legacy := []byte(`{"detail":{"bucket":{"name":"b"}}}`)
+	if IsEnvelope(legacy) {
+		t.Fatal("expected a legacy JSON message not to be mistaken for a wire envelope")
+	}
+}
+
+// TestGetDataAcceptsV0JSONPayload covers a v0 envelope: one built before
+// this package's VersionGob payloads existed, whose Payload is plain
+// JSON. GetData must still decode it so a message enqueued by an
+// unupgraded producer isn't dropped mid-rollout.
+func TestGetDataAcceptsV0JSONPayload(t *testing.T) {
+	payload, err := json.Marshal(testPayload{FileID: "v0", Count: 1})
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	e := Envelope{Version: VersionJSON, Kind: "wire.testPayload", Payload: payload}
+
+	var got testPayload
+	if err := e.GetData(&got); err != nil {
+		t.Fatalf("GetData: %v", err)
+	}
+	if got.FileID != "v0" || got.Count != 1 {
+		t.Fatalf("unexpected v0 payload: %+v", got)
+	}
+}
+
+// TestGetDataAcceptsV1GobPayload covers the current version explicitly,
+// alongside TestGetDataAcceptsV0JSONPayload, so the matrix documents both
+// supported versions rather than leaving v1 to be exercised only
+// incidentally by TestSetDataGetDataRoundTrip.
+func TestGetDataAcceptsV1GobPayload(t *testing.T) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(testPayload{FileID: "v1", Count: 2}); err != nil {
+		t.Fatalf("gob.Encode: %v", err)
+	}
+	e := Envelope{Version: VersionGob, Kind: "wire.testPayload", Payload: buf.Bytes()}
+
+	var got testPayload
+	if err := e.GetData(&got); err != nil {
+		t.Fatalf("GetData: %v", err)
+	}
+	if got.FileID != "v1" || got.Count != 2 {
+		t.Fatalf("unexpected v1 payload: %+v", got)
+	}
+}
+
+func TestGetDataRejectsUnknownVersion(t *testing.T) {
+	e := Envelope{Version: 99, Payload: []byte("whatever")}
+	var got testPayload
+	if err := e.GetData(&got); err == nil {
+		t.Fatal("expected an error for an unsupported envelope version, got nil")
+	}
+}
+
+func TestDecodeRejectsNonEnvelopeData(t *testing.T) {
+	if _, err := Decode([]byte(`{"not":"an envelope"}`)); err == nil {
+		t.Fatal("expected an error decoding non-envelope data, got nil")
+	}
+}
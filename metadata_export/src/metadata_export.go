@@ -0,0 +1,93 @@
+package main
+
+// metadata_export.go runs on an EventBridge schedule (hourly/daily) and
+// kicks off a DynamoDB PITR export of the file-metadata table into a
+// dedicated analytics S3 bucket. This is the first stage of the historical
+// reporting pipeline: metadata_parquet_transform picks up each export this
+// Lambda starts, converts it to partitioned Parquet, and analytics_api
+// queries the result with Athena - none of which touches the live table's
+// provisioned capacity the way a wide Scan would.
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Response represents the Lambda response structure
+type Response struct {
+	StatusCode int                    `json:"statusCode"`
+	Body       map[string]interface{} `json:"body"`
+}
+
+var (
+	dynamoClient *dynamodb.DynamoDB
+	tableArn     string
+	exportBucket string
+	exportPrefix string
+)
+
+func init() {
+	sess := session.Must(session.NewSession())
+	dynamoClient = dynamodb.New(sess)
+
+	tableArn = os.Getenv("FILE_METADATA_TABLE_ARN")
+	exportBucket = os.Getenv("ANALYTICS_EXPORT_BUCKET")
+	exportPrefix = os.Getenv("ANALYTICS_EXPORT_PREFIX")
+	if exportPrefix == "" {
+		exportPrefix = "dynamodb-exports/file-metadata"
+	}
+}
+
+func main() {
+	lambda.Start(handleRequest)
+}
+
+func handleRequest(ctx context.Context, event interface{}) (Response, error) {
+	log.Printf("Starting file-metadata PITR export to s3://%s/%s", exportBucket, exportPrefix)
+
+	if tableArn == "" || exportBucket == "" {
+		log.Printf("ERROR: FILE_METADATA_TABLE_ARN and ANALYTICS_EXPORT_BUCKET must both be set")
+		return Response{
+			StatusCode: 500,
+			Body: map[string]interface{}{
+				"error": "FILE_METADATA_TABLE_ARN and ANALYTICS_EXPORT_BUCKET must both be set",
+			},
+		}, nil
+	}
+
+	result, err := dynamoClient.ExportTableToPointInTime(&dynamodb.ExportTableToPointInTimeInput{
+		TableArn:     aws.String(tableArn),
+		S3Bucket:     aws.String(exportBucket),
+		S3Prefix:     aws.String(exportPrefix),
+		ExportFormat: aws.String(dynamodb.ExportFormatDynamodbJson),
+	})
+	if err != nil {
+		log.Printf("ERROR: Failed to start export: %v", err)
+		return Response{
+			StatusCode: 500,
+			Body: map[string]interface{}{
+				"error": err.Error(),
+			},
+		}, nil
+	}
+
+	exportArn := aws.StringValue(result.ExportDescription.ExportArn)
+	log.Printf("Started export %s (status: %s)", exportArn, aws.StringValue(result.ExportDescription.ExportStatus))
+
+	return Response{
+		StatusCode: 200,
+		Body: map[string]interface{}{
+			"message":    fmt.Sprintf("Export started at %s", time.Now().UTC().Format(time.RFC3339)),
+			"exportArn":  exportArn,
+			"exportTime": result.ExportDescription.ExportTime.UTC().Format(time.RFC3339),
+		},
+	}, nil
+}
@@ -0,0 +1,451 @@
+package main
+
+// metadata_parquet_transform.go is the second stage of the historical
+// reporting pipeline started by metadata_export: on its own EventBridge
+// schedule, it looks for file-metadata PITR exports that have finished
+// (dynamodb.ListExports) but haven't been converted yet, reads the
+// DynamoDB-JSON export off S3, and rewrites it as Parquet partitioned by
+// upload_date under s3://<bucket>/file_metadata/year=/month=/day=/ so
+// analytics_api's Athena queries never touch the live table. Each export is
+// marked done with a zero-byte S3 marker object so a later invocation
+// doesn't reprocess it.
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/athena"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/google/uuid"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// Response represents the Lambda response structure
+type Response struct {
+	StatusCode int                    `json:"statusCode"`
+	Body       map[string]interface{} `json:"body"`
+}
+
+var (
+	dynamoClient *dynamodb.DynamoDB
+	s3Client     *s3.S3
+	athenaClient *athena.Athena
+
+	tableArn          string
+	analyticsBucket   string
+	analyticsDatabase string
+	analyticsTable    string
+)
+
+func init() {
+	sess := session.Must(session.NewSession())
+	dynamoClient = dynamodb.New(sess)
+	s3Client = s3.New(sess)
+	athenaClient = athena.New(sess)
+
+	tableArn = os.Getenv("FILE_METADATA_TABLE_ARN")
+	analyticsBucket = os.Getenv("ANALYTICS_EXPORT_BUCKET")
+	analyticsDatabase = os.Getenv("ANALYTICS_GLUE_DATABASE")
+	analyticsTable = os.Getenv("ANALYTICS_GLUE_TABLE")
+	if analyticsTable == "" {
+		analyticsTable = "file_metadata"
+	}
+}
+
+func main() {
+	lambda.Start(handleRequest)
+}
+
+// fileMetadataRow is the Parquet schema written per DynamoDB item, covering
+// exactly the fields the analytics queries in analytics_api.go need.
+type fileMetadataRow struct {
+	FileID              string `parquet:"name=file_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	UploadTimestamp     string `parquet:"name=upload_timestamp, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ProcessingStatus    string `parquet:"name=processing_status, type=BYTE_ARRAY, convertedtype=UTF8"`
+	BatchJobFinalStatus string `parquet:"name=batch_job_final_status, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ErrorMessage        string `parquet:"name=error_message, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ProcessingCompleted string `parquet:"name=processing_completed, type=BYTE_ARRAY, convertedtype=UTF8"`
+	FileSize            int64  `parquet:"name=file_size, type=INT64"`
+}
+
+func handleRequest(ctx context.Context, event interface{}) (Response, error) {
+	if tableArn == "" || analyticsBucket == "" {
+		log.Printf("ERROR: FILE_METADATA_TABLE_ARN and ANALYTICS_EXPORT_BUCKET must both be set")
+		return Response{StatusCode: 500, Body: map[string]interface{}{"error": "FILE_METADATA_TABLE_ARN and ANALYTICS_EXPORT_BUCKET must both be set"}}, nil
+	}
+
+	exports, err := listUnprocessedExports()
+	if err != nil {
+		log.Printf("ERROR: Failed to list exports: %v", err)
+		return Response{StatusCode: 500, Body: map[string]interface{}{"error": err.Error()}}, nil
+	}
+
+	if len(exports) == 0 {
+		log.Printf("No unprocessed completed exports found")
+		return Response{StatusCode: 200, Body: map[string]interface{}{"message": "No unprocessed exports"}}, nil
+	}
+
+	processed := 0
+	var errs []string
+	for _, exportArn := range exports {
+		partitions, err := transformExport(exportArn)
+		if err != nil {
+			log.Printf("ERROR: Failed to transform export %s: %v", exportArn, err)
+			errs = append(errs, fmt.Sprintf("%s: %v", exportArn, err))
+			continue
+		}
+		if err := repairAnalyticsTable(); err != nil {
+			log.Printf("WARN: Failed to repair Glue table partitions after export %s: %v", exportArn, err)
+		}
+		if err := markExportProcessed(exportArn); err != nil {
+			log.Printf("WARN: Failed to mark export %s processed, it may be reprocessed: %v", exportArn, err)
+		}
+		log.Printf("Transformed export %s into %d partition(s)", exportArn, partitions)
+		processed++
+	}
+
+	return Response{
+		StatusCode: 200,
+		Body: map[string]interface{}{
+			"message":   fmt.Sprintf("Transformed %d of %d exports", processed, len(exports)),
+			"errors":    errs,
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+		},
+	}, nil
+}
+
+// listUnprocessedExports returns the ExportArn of every COMPLETED export for
+// tableArn that doesn't already have a "processed" marker in S3.
+func listUnprocessedExports() ([]string, error) {
+	var candidates []string
+
+	input := &dynamodb.ListExportsInput{TableArn: aws.String(tableArn), MaxResults: aws.Int64(25)}
+	var summaries []*dynamodb.ExportSummary
+	for {
+		result, err := dynamoClient.ListExports(input)
+		if err != nil {
+			return nil, fmt.Errorf("ListExports failed: %v", err)
+		}
+		summaries = append(summaries, result.ExportSummaries...)
+		if aws.StringValue(result.NextToken) == "" {
+			break
+		}
+		input.NextToken = result.NextToken
+	}
+
+	for _, summary := range summaries {
+		if aws.StringValue(summary.ExportStatus) != dynamodb.ExportStatusCompleted {
+			continue
+		}
+		exportArn := aws.StringValue(summary.ExportArn)
+		done, err := exportMarkerExists(exportArn)
+		if err != nil {
+			log.Printf("WARN: Failed to check marker for export %s: %v", exportArn, err)
+			continue
+		}
+		if !done {
+			candidates = append(candidates, exportArn)
+		}
+	}
+
+	return candidates, nil
+}
+
+func exportMarkerKey(exportArn string) string {
+	return fmt.Sprintf("processed-markers/%s.done", path.Base(exportArn))
+}
+
+func exportMarkerExists(exportArn string) (bool, error) {
+	_, err := s3Client.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(analyticsBucket),
+		Key:    aws.String(exportMarkerKey(exportArn)),
+	})
+	if err == nil {
+		return true, nil
+	}
+	if strings.Contains(err.Error(), s3.ErrCodeNoSuchKey) || strings.Contains(err.Error(), "NotFound") {
+		return false, nil
+	}
+	return false, err
+}
+
+func markExportProcessed(exportArn string) error {
+	_, err := s3Client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(analyticsBucket),
+		Key:    aws.String(exportMarkerKey(exportArn)),
+		Body:   strings.NewReader(time.Now().UTC().Format(time.RFC3339)),
+	})
+	return err
+}
+
+// transformExport reads one completed export's manifest, converts every
+// data file to Parquet rows grouped by upload_date, and writes one Parquet
+// object per partition. It returns the number of partitions written.
+func transformExport(exportArn string) (int, error) {
+	describeResult, err := dynamoClient.DescribeExport(&dynamodb.DescribeExportInput{ExportArn: aws.String(exportArn)})
+	if err != nil {
+		return 0, fmt.Errorf("DescribeExport failed: %v", err)
+	}
+	manifestKey := aws.StringValue(describeResult.ExportDescription.ExportManifest)
+	if manifestKey == "" {
+		return 0, fmt.Errorf("export %s has no manifest yet", exportArn)
+	}
+
+	dataFileKeys, err := readManifestDataFileKeys(manifestKey)
+	if err != nil {
+		return 0, err
+	}
+
+	partitions := make(map[string][]fileMetadataRow)
+	for _, dataFileKey := range dataFileKeys {
+		if err := readDataFileInto(dataFileKey, partitions); err != nil {
+			return 0, fmt.Errorf("failed to read data file %s: %v", dataFileKey, err)
+		}
+	}
+
+	for uploadDate, rows := range partitions {
+		if err := writePartitionParquet(uploadDate, rows); err != nil {
+			return 0, fmt.Errorf("failed to write partition %s: %v", uploadDate, err)
+		}
+	}
+
+	return len(partitions), nil
+}
+
+// readManifestDataFileKeys resolves manifest-summary.json -> manifest-files.json
+// and returns the S3 key of every gzipped data file the export wrote.
+func readManifestDataFileKeys(manifestSummaryKey string) ([]string, error) {
+	summaryBytes, err := getS3Object(manifestSummaryKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest summary: %v", err)
+	}
+
+	var summary struct {
+		ManifestFilesS3Key string `json:"manifestFilesS3Key"`
+	}
+	if err := json.Unmarshal(summaryBytes, &summary); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest summary: %v", err)
+	}
+
+	manifestBytes, err := getS3Object(summary.ManifestFilesS3Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest files list: %v", err)
+	}
+
+	var keys []string
+	scanner := bufio.NewScanner(strings.NewReader(string(manifestBytes)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var entry struct {
+			DataFileS3Key string `json:"dataFileS3Key"`
+		}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			continue
+		}
+		if entry.DataFileS3Key != "" {
+			keys = append(keys, entry.DataFileS3Key)
+		}
+	}
+
+	return keys, nil
+}
+
+func getS3Object(key string) ([]byte, error) {
+	resp, err := s3Client.GetObject(&s3.GetObjectInput{Bucket: aws.String(analyticsBucket), Key: aws.String(key)})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return io.ReadAll(resp.Body)
+}
+
+// dynamoAttr is the subset of the DynamoDB-JSON export's attribute-value
+// encoding this transform reads.
+type dynamoAttr struct {
+	S *string `json:"S,omitempty"`
+	N *string `json:"N,omitempty"`
+}
+
+type exportItem struct {
+	Item map[string]dynamoAttr `json:"Item"`
+}
+
+func readDataFileInto(dataFileKey string, partitions map[string][]fileMetadataRow) error {
+	resp, err := s3Client.GetObject(&s3.GetObjectInput{Bucket: aws.String(analyticsBucket), Key: aws.String(dataFileKey)})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	gzReader, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to open gzip data file: %v", err)
+	}
+	defer gzReader.Close()
+
+	scanner := bufio.NewScanner(gzReader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var record exportItem
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			log.Printf("WARN: skipping unparseable export record: %v", err)
+			continue
+		}
+
+		uploadDate := attrString(record.Item, "upload_date")
+		if uploadDate == "" {
+			uploadDate = "unknown"
+		}
+		partitions[uploadDate] = append(partitions[uploadDate], fileMetadataRow{
+			FileID:              attrString(record.Item, "file_id"),
+			UploadTimestamp:     attrString(record.Item, "upload_timestamp"),
+			ProcessingStatus:    attrString(record.Item, "processing_status"),
+			BatchJobFinalStatus: attrString(record.Item, "batch_job_final_status"),
+			ErrorMessage:        attrString(record.Item, "error_message"),
+			ProcessingCompleted: attrString(record.Item, "processing_completed"),
+			FileSize:            attrInt64(record.Item, "file_size"),
+		})
+	}
+
+	return scanner.Err()
+}
+
+func attrString(item map[string]dynamoAttr, key string) string {
+	if attr, ok := item[key]; ok && attr.S != nil {
+		return *attr.S
+	}
+	return ""
+}
+
+func attrInt64(item map[string]dynamoAttr, key string) int64 {
+	attr, ok := item[key]
+	if !ok || attr.N == nil {
+		return 0
+	}
+	var value int64
+	fmt.Sscanf(*attr.N, "%d", &value)
+	return value
+}
+
+// writePartitionParquet writes rows as Parquet to /tmp (Lambda's only
+// writable path), then uploads the file under a year=/month=/day= Hive
+// partition derived from uploadDate ("YYYY-MM-DD").
+func writePartitionParquet(uploadDate string, rows []fileMetadataRow) error {
+	parts := strings.Split(uploadDate, "-")
+	year, month, day := "unknown", "unknown", "unknown"
+	if len(parts) == 3 {
+		year, month, day = parts[0], parts[1], parts[2]
+	}
+
+	localPath := fmt.Sprintf("/tmp/%s.parquet", uuid.New().String())
+	fw, err := local.NewLocalFileWriter(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to open local parquet file: %v", err)
+	}
+	defer os.Remove(localPath)
+
+	pw, err := writer.NewParquetWriter(fw, new(fileMetadataRow), 4)
+	if err != nil {
+		fw.Close()
+		return fmt.Errorf("failed to create parquet writer: %v", err)
+	}
+	pw.RowGroupSize = 128 * 1024 * 1024
+	pw.CompressionType = parquet.CompressionCodec_SNAPPY
+
+	for _, row := range rows {
+		if err := pw.Write(row); err != nil {
+			return fmt.Errorf("failed to write parquet row: %v", err)
+		}
+	}
+	if err := pw.WriteStop(); err != nil {
+		fw.Close()
+		return fmt.Errorf("failed to finalize parquet file: %v", err)
+	}
+	fw.Close()
+
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to read local parquet file: %v", err)
+	}
+
+	key := fmt.Sprintf("file_metadata/year=%s/month=%s/day=%s/part-%s.parquet", year, month, day, uuid.New().String())
+	if _, err := s3Client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(analyticsBucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	}); err != nil {
+		return fmt.Errorf("failed to upload parquet partition: %v", err)
+	}
+
+	log.Printf("Wrote %d rows to s3://%s/%s", len(rows), analyticsBucket, key)
+	return nil
+}
+
+// repairAnalyticsTable runs MSCK REPAIR TABLE so Athena picks up any new
+// year=/month=/day= partitions this run added, without hand-maintaining a
+// Glue partition list.
+func repairAnalyticsTable() error {
+	if analyticsDatabase == "" {
+		return fmt.Errorf("ANALYTICS_GLUE_DATABASE not configured")
+	}
+
+	startResult, err := athenaClient.StartQueryExecution(&athena.StartQueryExecutionInput{
+		QueryString: aws.String(fmt.Sprintf("MSCK REPAIR TABLE %s", analyticsTable)),
+		QueryExecutionContext: &athena.QueryExecutionContext{
+			Database: aws.String(analyticsDatabase),
+		},
+		ResultConfiguration: &athena.ResultConfiguration{
+			OutputLocation: aws.String(fmt.Sprintf("s3://%s/athena-results/", analyticsBucket)),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start MSCK REPAIR: %v", err)
+	}
+
+	return waitForAthenaQuery(aws.StringValue(startResult.QueryExecutionId))
+}
+
+func waitForAthenaQuery(queryExecutionID string) error {
+	for i := 0; i < 30; i++ {
+		result, err := athenaClient.GetQueryExecution(&athena.GetQueryExecutionInput{QueryExecutionId: aws.String(queryExecutionID)})
+		if err != nil {
+			return err
+		}
+
+		state := aws.StringValue(result.QueryExecution.Status.State)
+		switch state {
+		case athena.QueryExecutionStateSucceeded:
+			return nil
+		case athena.QueryExecutionStateFailed, athena.QueryExecutionStateCancelled:
+			return fmt.Errorf("query %s ended in state %s: %s", queryExecutionID, state, aws.StringValue(result.QueryExecution.Status.StateChangeReason))
+		}
+
+		time.Sleep(2 * time.Second)
+	}
+
+	return fmt.Errorf("query %s did not finish within the polling budget", queryExecutionID)
+}
@@ -0,0 +1,100 @@
+// Package batchjob factors out the AWS Batch job submission that
+// sqs_processor (on first upload) and dead_job_detector (on a manual
+// retry) both need, so a resubmitted file gets the same base
+// bucket/key/fileId/table Parameters and environment the OCR container
+// gets on the first attempt. The per-template settings sqs_processor adds
+// on first upload (the Extra* fields below) are not applied to
+// dead_job_detector's resubmissions.
+package batchjob
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/batch"
+)
+
+// SubmitInput carries everything needed to submit one OCR processing job.
+// The Extra* fields are all optional and let sqs_processor's YAML-driven
+// job templates (see pkg/jobconfig) layer per-template settings on top of
+// the default bucket/key/fileId/table shape every job gets; callers that
+// leave them zero-valued, like dead_job_detector's manual retry, get
+// exactly the original submission shape.
+type SubmitInput struct {
+	BucketName    string
+	ObjectKey     string
+	FileID        string
+	JobQueue      string
+	JobDefinition string
+	DynamoTable   string
+
+	ExtraParameters map[string]string
+	ExtraEnv        map[string]string
+	VCPUs           float64
+	MemoryMiB       int64
+	RetryAttempts   int64
+	TimeoutSeconds  int64
+	Tags            map[string]string
+}
+
+// Submit submits a Batch job for a single uploaded file and returns the
+// new job's ID and name.
+func Submit(client *batch.Batch, input SubmitInput) (jobID, jobName string, err error) {
+	jobName = fmt.Sprintf("process-file-%s-%s", input.FileID, time.Now().Format("20060102150405"))
+
+	parameters := map[string]*string{
+		"bucket": aws.String(input.BucketName),
+		"key":    aws.String(input.ObjectKey),
+		"fileId": aws.String(input.FileID),
+	}
+	for k, v := range input.ExtraParameters {
+		parameters[k] = aws.String(v)
+	}
+
+	environment := []*batch.KeyValuePair{
+		{Name: aws.String("S3_BUCKET"), Value: aws.String(input.BucketName)},
+		{Name: aws.String("S3_KEY"), Value: aws.String(input.ObjectKey)},
+		{Name: aws.String("FILE_ID"), Value: aws.String(input.FileID)},
+		{Name: aws.String("DYNAMODB_TABLE"), Value: aws.String(input.DynamoTable)},
+	}
+	for k, v := range input.ExtraEnv {
+		environment = append(environment, &batch.KeyValuePair{Name: aws.String(k), Value: aws.String(v)})
+	}
+
+	containerOverrides := &batch.ContainerOverrides{Environment: environment}
+	if input.VCPUs > 0 {
+		containerOverrides.Vcpus = aws.Int64(int64(input.VCPUs))
+	}
+	if input.MemoryMiB > 0 {
+		containerOverrides.Memory = aws.Int64(input.MemoryMiB)
+	}
+
+	submitInput := &batch.SubmitJobInput{
+		JobName:            aws.String(jobName),
+		JobQueue:           aws.String(input.JobQueue),
+		JobDefinition:      aws.String(input.JobDefinition),
+		Parameters:         parameters,
+		ContainerOverrides: containerOverrides,
+	}
+	if input.RetryAttempts > 0 {
+		submitInput.RetryStrategy = &batch.RetryStrategy{Attempts: aws.Int64(input.RetryAttempts)}
+	}
+	if input.TimeoutSeconds > 0 {
+		submitInput.Timeout = &batch.JobTimeout{AttemptDurationSeconds: aws.Int64(input.TimeoutSeconds)}
+	}
+	if len(input.Tags) > 0 {
+		tags := make(map[string]*string, len(input.Tags))
+		for k, v := range input.Tags {
+			tags[k] = aws.String(v)
+		}
+		submitInput.Tags = tags
+	}
+
+	result, err := client.SubmitJob(submitInput)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to submit batch job: %v", err)
+	}
+
+	return *result.JobId, jobName, nil
+}
@@ -0,0 +1,138 @@
+// Package batchreport defines the durable audit-trail record this
+// pipeline writes to S3 once a Batch job reaches a terminal state, so an
+// operator has more than DynamoDB's latest-state-only view of what a
+// completed job actually did. batch_status_reconciliation and
+// dead_job_detector both write a Report after resolving a job;
+// report_reader serves it back over HTTP and report_ttl_sweeper deletes
+// it once it ages out.
+package batchreport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/CaringalML/OCR-Processing-Pipeline-Using-AWS-Batch-Serverless-Architecture/pkg/blobstore"
+)
+
+// reportPrefix is the root of the reports/ tree every key below lives
+// under, so report_reader and report_ttl_sweeper can List it without
+// hardcoding the literal string themselves.
+const reportPrefix = "reports/"
+
+// FileDetail is the per-file outcome of a Batch job. Every job processes
+// exactly one file today, so Report.Files always has one entry, but the
+// shape is a slice so a future array-job submission path doesn't need a
+// new report format.
+type FileDetail struct {
+	FileID string `json:"fileId"`
+	S3Key  string `json:"s3Key"`
+	Status string `json:"status"`
+}
+
+// Report is the durable record written to
+// s3://{bucket}/reports/{YYYY}/{MM}/{DD}/{jobId}.json once a Batch job
+// reaches SUCCEEDED, FAILED, or CANCELLED.
+type Report struct {
+	JobID                  string       `json:"jobId"`
+	JobName                string       `json:"jobName,omitempty"`
+	TotalNumberOfTasks     int          `json:"totalNumberOfTasks"`
+	NumberOfTasksSucceeded int          `json:"numberOfTasksSucceeded"`
+	NumberOfTasksFailed    int          `json:"numberOfTasksFailed"`
+	StartedAt              string       `json:"startedAt,omitempty"`
+	FinishedAt             string       `json:"finishedAt"`
+	StatusReason           string       `json:"statusReason,omitempty"`
+	Files                  []FileDetail `json:"files,omitempty"`
+}
+
+// Key returns the report's canonical S3 key, partitioned by the day it
+// finished, so ListOlderThan can tell a report's age from its key alone
+// without reading the object.
+func Key(finishedAt time.Time, jobID string) string {
+	return fmt.Sprintf("%s%s/%s.json", reportPrefix, finishedAt.Format("2006/01/02"), jobID)
+}
+
+// Write marshals report and PUTs it to store at its canonical key. If
+// report.FinishedAt doesn't parse as RFC3339, the current time is used
+// for the key's date partition only - the stored FinishedAt field is left
+// as-is.
+func Write(ctx context.Context, store blobstore.Store, report Report) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to marshal batch job report: %v", err)
+	}
+
+	finishedAt, err := time.Parse(time.RFC3339, report.FinishedAt)
+	if err != nil {
+		finishedAt = time.Now().UTC()
+	}
+
+	if _, err := store.Put(ctx, Key(finishedAt, report.JobID), bytes.NewReader(data), map[string]string{
+		"content-type": "application/json",
+	}); err != nil {
+		return fmt.Errorf("failed to write batch job report: %v", err)
+	}
+	return nil
+}
+
+// Find locates the report for jobID by listing the reports/ tree, since
+// the canonical key is partitioned by a finish date the caller doesn't
+// know in advance. It returns the key, for the caller to pass to
+// blobstore.Store.Get.
+func Find(ctx context.Context, store blobstore.Store, jobID string) (string, error) {
+	keys, err := store.List(ctx, reportPrefix)
+	if err != nil {
+		return "", fmt.Errorf("failed to list reports: %v", err)
+	}
+
+	suffix := "/" + jobID + ".json"
+	for _, key := range keys {
+		if strings.HasSuffix(key, suffix) {
+			return key, nil
+		}
+	}
+	return "", fmt.Errorf("no report found for job %s", jobID)
+}
+
+// ListOlderThan returns the keys of every report that finished before
+// cutoff, for report_ttl_sweeper to delete.
+func ListOlderThan(ctx context.Context, store blobstore.Store, cutoff time.Time) ([]string, error) {
+	keys, err := store.List(ctx, reportPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reports: %v", err)
+	}
+
+	var stale []string
+	for _, key := range keys {
+		day, ok := dayFromKey(key)
+		if !ok {
+			continue
+		}
+		if day.Before(cutoff) {
+			stale = append(stale, key)
+		}
+	}
+	return stale, nil
+}
+
+// dayFromKey parses the YYYY/MM/DD partition out of a reports/ key, e.g.
+// "reports/2026/07/27/abcd-1234.json" -> 2026-07-27.
+func dayFromKey(key string) (time.Time, bool) {
+	if !strings.HasPrefix(key, reportPrefix) {
+		return time.Time{}, false
+	}
+
+	parts := strings.Split(strings.TrimPrefix(key, reportPrefix), "/")
+	if len(parts) < 4 {
+		return time.Time{}, false
+	}
+
+	day, err := time.Parse("2006/01/02", strings.Join(parts[:3], "/"))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return day, true
+}
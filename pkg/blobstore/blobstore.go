@@ -0,0 +1,105 @@
+// Package blobstore is the storage abstraction behind file_uploader's three
+// upload paths (single-shot, multipart, presigned): a Store interface
+// narrow enough to be satisfied by AWS S3, any S3-compatible endpoint
+// (MinIO), or Aliyun OSS, selected at startup by BLOBSTORE_PROVIDER so the
+// same binary can run in cn-* regions where S3 isn't available. Every
+// record written through a Store also carries the Store's Provider so
+// downstream consumers - the Batch worker and the status-updater Lambda -
+// know which backend to read the object back from.
+package blobstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Provider identifies which backend a Store talks to. It's persisted onto
+// the DynamoDB record as storage_provider.
+type Provider string
+
+const (
+	ProviderS3    Provider = "s3"
+	ProviderMinIO Provider = "minio"
+	ProviderOSS   Provider = "oss"
+)
+
+// PresignOp identifies which HTTP method a presigned URL is valid for.
+type PresignOp string
+
+const (
+	PresignPut PresignOp = "put"
+	PresignGet PresignOp = "get"
+)
+
+// Head is the subset of object metadata callers need, independent of which
+// backend served it.
+type Head struct {
+	ContentLength int64
+	ContentType   string
+	ETag          string
+}
+
+// CompletedPart is one entry of the ordered part list passed to Complete.
+type CompletedPart struct {
+	PartNumber int64
+	ETag       string
+}
+
+// Store is the set of storage operations file_uploader's three upload paths
+// need. Every method operates on the bucket the Store was constructed with.
+type Store interface {
+	// Provider reports which backend this Store talks to, so callers can
+	// stamp it onto the DynamoDB record as storage_provider.
+	Provider() Provider
+
+	Put(ctx context.Context, key string, body io.Reader, meta map[string]string) (etag string, err error)
+	// Get reads an object's body back. None of file_uploader's three upload
+	// paths need this - they only ever write - but report_reader does, to
+	// serve a batchreport.Report's JSON body over HTTP.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Presign(ctx context.Context, op PresignOp, key string, ttl time.Duration) (string, error)
+	InitiateMultipart(ctx context.Context, key string, meta map[string]string) (uploadID string, err error)
+	UploadPart(ctx context.Context, key, uploadID string, partNumber int64, body io.Reader) (etag string, err error)
+	Complete(ctx context.Context, key, uploadID string, parts []CompletedPart) (etag string, err error)
+	Abort(ctx context.Context, key, uploadID string) error
+	Head(ctx context.Context, key string) (Head, error)
+	Delete(ctx context.Context, key string) error
+
+	// List returns every key under prefix. It exists for callers that, unlike
+	// file_uploader's three upload paths, don't already know the exact key
+	// they want - report_reader and report_ttl_sweeper use it to find
+	// batchreport objects by job ID or by age across the reports/ tree.
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// New builds the Store selected by BLOBSTORE_PROVIDER ("s3", the default,
+// "minio", or "oss") for bucket. MinIO and any other S3-compatible endpoint
+// are configured via BLOBSTORE_ENDPOINT; OSS via BLOBSTORE_ENDPOINT plus
+// OSS_ACCESS_KEY_ID/OSS_ACCESS_KEY_SECRET.
+func New(bucket string) (Store, error) {
+	switch Provider(providerFromEnv()) {
+	case ProviderOSS:
+		return newOSSStore(bucket)
+	case ProviderMinIO:
+		return newS3CompatibleStore(ProviderMinIO, bucket)
+	case ProviderS3, "":
+		return newS3CompatibleStore(ProviderS3, bucket)
+	default:
+		return nil, fmt.Errorf("blobstore: unsupported BLOBSTORE_PROVIDER %q", providerFromEnv())
+	}
+}
+
+func providerFromEnv() string {
+	provider := os.Getenv("BLOBSTORE_PROVIDER")
+	if provider == "" {
+		return string(ProviderS3)
+	}
+	return provider
+}
+
+func endpointFromEnv() string {
+	return os.Getenv("BLOBSTORE_ENDPOINT")
+}
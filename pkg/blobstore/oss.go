@@ -0,0 +1,177 @@
+package blobstore
+
+// oss.go backs Store for BLOBSTORE_PROVIDER=oss, so customers in cn-*
+// regions where S3 isn't available can run the same upload Lambda against
+// Alibaba Cloud Object Storage Service instead.
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+)
+
+type ossStore struct {
+	bucket *oss.Bucket
+}
+
+func newOSSStore(bucketName string) (Store, error) {
+	endpoint := endpointFromEnv()
+	if endpoint == "" {
+		return nil, fmt.Errorf("BLOBSTORE_ENDPOINT must be set when BLOBSTORE_PROVIDER=oss")
+	}
+	accessKeyID := os.Getenv("OSS_ACCESS_KEY_ID")
+	accessKeySecret := os.Getenv("OSS_ACCESS_KEY_SECRET")
+
+	client, err := oss.New(endpoint, accessKeyID, accessKeySecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OSS client: %v", err)
+	}
+
+	bucket, err := client.Bucket(bucketName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve OSS bucket %s: %v", bucketName, err)
+	}
+
+	return &ossStore{bucket: bucket}, nil
+}
+
+func (s *ossStore) Provider() Provider { return ProviderOSS }
+
+func (s *ossStore) Put(ctx context.Context, key string, body io.Reader, meta map[string]string) (string, error) {
+	if err := s.bucket.PutObject(key, body, metaOptions(meta)...); err != nil {
+		return "", err
+	}
+	return s.etagOf(key)
+}
+
+func (s *ossStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return s.bucket.GetObject(key)
+}
+
+func (s *ossStore) Presign(ctx context.Context, op PresignOp, key string, ttl time.Duration) (string, error) {
+	method := oss.HTTPPut
+	if op == PresignGet {
+		method = oss.HTTPGet
+	}
+	return s.bucket.SignURL(key, method, int64(ttl.Seconds()))
+}
+
+func (s *ossStore) InitiateMultipart(ctx context.Context, key string, meta map[string]string) (string, error) {
+	result, err := s.bucket.InitiateMultipartUpload(key, metaOptions(meta)...)
+	if err != nil {
+		return "", err
+	}
+	return result.UploadID, nil
+}
+
+func (s *ossStore) UploadPart(ctx context.Context, key, uploadID string, partNumber int64, body io.Reader) (string, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read part body: %v", err)
+	}
+
+	imur := oss.InitiateMultipartUploadResult{Bucket: s.bucket.BucketName, Key: key, UploadID: uploadID}
+	part, err := s.bucket.UploadPart(imur, bytesReader(data), int64(len(data)), int(partNumber))
+	if err != nil {
+		return "", err
+	}
+	return part.ETag, nil
+}
+
+func (s *ossStore) Complete(ctx context.Context, key, uploadID string, parts []CompletedPart) (string, error) {
+	imur := oss.InitiateMultipartUploadResult{Bucket: s.bucket.BucketName, Key: key, UploadID: uploadID}
+
+	ossParts := make([]oss.UploadPart, len(parts))
+	for i, part := range parts {
+		ossParts[i] = oss.UploadPart{PartNumber: int(part.PartNumber), ETag: part.ETag}
+	}
+
+	result, err := s.bucket.CompleteMultipartUpload(imur, ossParts)
+	if err != nil {
+		return "", err
+	}
+	return result.ETag, nil
+}
+
+func (s *ossStore) Abort(ctx context.Context, key, uploadID string) error {
+	imur := oss.InitiateMultipartUploadResult{Bucket: s.bucket.BucketName, Key: key, UploadID: uploadID}
+	return s.bucket.AbortMultipartUpload(imur)
+}
+
+func (s *ossStore) Head(ctx context.Context, key string) (Head, error) {
+	header, err := s.bucket.GetObjectDetailedMeta(key)
+	if err != nil {
+		return Head{}, err
+	}
+
+	var length int64
+	fmt.Sscanf(header.Get("Content-Length"), "%d", &length)
+
+	return Head{
+		ContentLength: length,
+		ContentType:   header.Get("Content-Type"),
+		ETag:          header.Get("ETag"),
+	}, nil
+}
+
+func (s *ossStore) Delete(ctx context.Context, key string) error {
+	return s.bucket.DeleteObject(key)
+}
+
+func (s *ossStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	marker := ""
+	for {
+		result, err := s.bucket.ListObjects(oss.Prefix(prefix), oss.Marker(marker))
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range result.Objects {
+			keys = append(keys, obj.Key)
+		}
+		if !result.IsTruncated {
+			break
+		}
+		marker = result.NextMarker
+	}
+	return keys, nil
+}
+
+func (s *ossStore) etagOf(key string) (string, error) {
+	head, err := s.Head(context.Background(), key)
+	if err != nil {
+		return "", err
+	}
+	return head.ETag, nil
+}
+
+func metaOptions(meta map[string]string) []oss.Option {
+	options := make([]oss.Option, 0, len(meta))
+	for k, v := range meta {
+		options = append(options, oss.Meta(k, v))
+	}
+	return options
+}
+
+// bytesReader avoids importing "bytes" just for this one call site.
+func bytesReader(data []byte) io.Reader {
+	return &sliceReader{data: data}
+}
+
+type sliceReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *sliceReader) Read(p []byte) (int, error) {
+	if r.pos >= len(r.data) {
+		return 0, io.EOF
+	}
+	n := copy(p, r.data[r.pos:])
+	r.pos += n
+	return n, nil
+}
@@ -0,0 +1,209 @@
+package blobstore
+
+// s3.go backs Store for both BLOBSTORE_PROVIDER=s3 and =minio: the
+// aws-sdk-go v1 S3 client talks to any S3-compatible endpoint once given a
+// custom Endpoint and S3ForcePathStyle, so MinIO doesn't need a second
+// client library the way aws_batch's SDK-v2 ObjectStore needs minio-go.
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+type s3Store struct {
+	client   *s3.S3
+	bucket   string
+	provider Provider
+}
+
+func newS3CompatibleStore(provider Provider, bucket string) (Store, error) {
+	cfg := aws.NewConfig()
+	if provider == ProviderMinIO {
+		endpoint := endpointFromEnv()
+		if endpoint == "" {
+			return nil, fmt.Errorf("BLOBSTORE_ENDPOINT must be set when BLOBSTORE_PROVIDER=minio")
+		}
+		cfg = cfg.WithEndpoint(endpoint).WithS3ForcePathStyle(true)
+	}
+
+	sess, err := session.NewSession(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session for provider %s: %v", provider, err)
+	}
+
+	return &s3Store{client: s3.New(sess), bucket: bucket, provider: provider}, nil
+}
+
+func (s *s3Store) Provider() Provider { return s.provider }
+
+func (s *s3Store) Put(ctx context.Context, key string, body io.Reader, meta map[string]string) (string, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read upload body: %v", err)
+	}
+
+	result, err := s.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(key),
+		Body:     bytes.NewReader(data),
+		Metadata: stringMapToAWS(meta),
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.Trim(aws.StringValue(result.ETag), `"`), nil
+}
+
+func (s *s3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	result, err := s.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.Body, nil
+}
+
+func (s *s3Store) Presign(ctx context.Context, op PresignOp, key string, ttl time.Duration) (string, error) {
+	switch op {
+	case PresignPut:
+		req, _ := s.client.PutObjectRequest(&s3.PutObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(key)})
+		return req.Presign(ttl)
+	case PresignGet:
+		req, _ := s.client.GetObjectRequest(&s3.GetObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(key)})
+		return req.Presign(ttl)
+	default:
+		return "", fmt.Errorf("blobstore: unsupported presign op %q", op)
+	}
+}
+
+func (s *s3Store) InitiateMultipart(ctx context.Context, key string, meta map[string]string) (string, error) {
+	result, err := s.client.CreateMultipartUploadWithContext(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(key),
+		Metadata: stringMapToAWS(meta),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(result.UploadId), nil
+}
+
+func (s *s3Store) UploadPart(ctx context.Context, key, uploadID string, partNumber int64, body io.Reader) (string, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read part body: %v", err)
+	}
+
+	result, err := s.client.UploadPartWithContext(ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(s.bucket),
+		Key:        aws.String(key),
+		UploadId:   aws.String(uploadID),
+		PartNumber: aws.Int64(partNumber),
+		Body:       bytes.NewReader(data),
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.Trim(aws.StringValue(result.ETag), `"`), nil
+}
+
+func (s *s3Store) Complete(ctx context.Context, key, uploadID string, parts []CompletedPart) (string, error) {
+	sorted := append([]CompletedPart(nil), parts...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].PartNumber < sorted[j].PartNumber })
+
+	completedParts := make([]*s3.CompletedPart, len(sorted))
+	for i, part := range sorted {
+		completedParts[i] = &s3.CompletedPart{
+			PartNumber: aws.Int64(part.PartNumber),
+			ETag:       aws.String(part.ETag),
+		}
+	}
+
+	result, err := s.client.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s.bucket),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: completedParts},
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.Trim(aws.StringValue(result.ETag), `"`), nil
+}
+
+func (s *s3Store) Abort(ctx context.Context, key, uploadID string) error {
+	_, err := s.client.AbortMultipartUploadWithContext(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	return err
+}
+
+func (s *s3Store) Head(ctx context.Context, key string) (Head, error) {
+	result, err := s.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return Head{}, err
+	}
+	return Head{
+		ContentLength: aws.Int64Value(result.ContentLength),
+		ContentType:   aws.StringValue(result.ContentType),
+		ETag:          strings.Trim(aws.StringValue(result.ETag), `"`),
+	}, nil
+}
+
+func (s *s3Store) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+func (s *s3Store) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	var continuationToken *string
+	for {
+		result, err := s.client.ListObjectsV2WithContext(ctx, &s3.ListObjectsV2Input{
+			Bucket:            aws.String(s.bucket),
+			Prefix:            aws.String(prefix),
+			ContinuationToken: continuationToken,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range result.Contents {
+			keys = append(keys, aws.StringValue(obj.Key))
+		}
+		if !aws.BoolValue(result.IsTruncated) {
+			break
+		}
+		continuationToken = result.NextContinuationToken
+	}
+	return keys, nil
+}
+
+func stringMapToAWS(meta map[string]string) map[string]*string {
+	if len(meta) == 0 {
+		return nil
+	}
+	out := make(map[string]*string, len(meta))
+	for k, v := range meta {
+		out[k] = aws.String(v)
+	}
+	return out
+}
@@ -0,0 +1,105 @@
+// Package jobconfig loads the declarative job-template config sqs_processor
+// uses to route an uploaded file to a Batch queue/definition by S3 key
+// prefix, instead of dispatching every file through one hardcoded
+// queue/definition pair baked into env vars. The config lives as YAML at
+// s3://{configBucket}/jobs.yaml and is reloaded whenever an EventBridge
+// config.updated event arrives, so an operator can add a queue for a new
+// file type without redeploying the dispatcher.
+package jobconfig
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ContainerOverrides mirrors the subset of batch.ContainerOverrides an
+// operator can tune per job template.
+type ContainerOverrides struct {
+	VCPUs     float64           `yaml:"vcpus"`
+	MemoryMiB int64             `yaml:"memory"`
+	Env       map[string]string `yaml:"env"`
+}
+
+// RetryStrategy mirrors batch.RetryStrategy.
+type RetryStrategy struct {
+	Attempts int64 `yaml:"attempts"`
+}
+
+// Template is one named routing rule: every uploaded file whose S3 key
+// starts with Prefix is submitted to JobQueue/JobDefinition with these
+// settings. Parameters supports ${bucket}, ${key}, and ${fileId}
+// interpolation, resolved per file by ResolveParameters.
+type Template struct {
+	Name               string            `yaml:"name"`
+	Prefix             string            `yaml:"prefix"`
+	JobQueue           string            `yaml:"jobQueue"`
+	JobDefinition      string            `yaml:"jobDefinition"`
+	ContainerOverrides ContainerOverrides `yaml:"containerOverrides"`
+	RetryStrategy      RetryStrategy      `yaml:"retryStrategy"`
+	TimeoutSeconds     int64              `yaml:"timeout"`
+	Tags               map[string]string  `yaml:"tags"`
+	Parameters         map[string]string  `yaml:"parameters"`
+}
+
+// Config is the top-level jobs.yaml shape: a list of named templates tried
+// in order, first Prefix match wins.
+type Config struct {
+	Templates []Template `yaml:"templates"`
+}
+
+// Parse strictly unmarshals data as a Config, rejecting unknown fields so a
+// typo'd key in an operator's jobs.yaml (e.g. "jobQeue") fails loudly at
+// load time instead of silently being ignored and falling back to a zero
+// value. It also rejects a config with no usable templates, since that
+// would leave the dispatcher unable to route any file.
+func Parse(data []byte) (*Config, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var cfg Config
+	if err := decoder.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse job config: %v", err)
+	}
+
+	if len(cfg.Templates) == 0 {
+		return nil, fmt.Errorf("job config has no templates")
+	}
+	for i, tmpl := range cfg.Templates {
+		if tmpl.Prefix == "" {
+			return nil, fmt.Errorf("template %d (%q) has no prefix", i, tmpl.Name)
+		}
+		if tmpl.JobQueue == "" || tmpl.JobDefinition == "" {
+			return nil, fmt.Errorf("template %d (%q) must set jobQueue and jobDefinition", i, tmpl.Name)
+		}
+	}
+	return &cfg, nil
+}
+
+// Match returns the first template whose Prefix the object key starts
+// with, in file order - so a more specific prefix must be listed ahead of
+// a more general fallback it would otherwise be shadowed by.
+func (c *Config) Match(objectKey string) (*Template, error) {
+	for i := range c.Templates {
+		if strings.HasPrefix(objectKey, c.Templates[i].Prefix) {
+			return &c.Templates[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no job template matches key %s", objectKey)
+}
+
+// ResolveParameters interpolates ${bucket}, ${key}, and ${fileId} into the
+// template's Parameters map, so a template can declare e.g.
+// parameters: {source: "s3://${bucket}/${key}"} once and have it apply to
+// every file the template matches.
+func (t *Template) ResolveParameters(bucket, key, fileID string) map[string]string {
+	replacer := strings.NewReplacer("${bucket}", bucket, "${key}", key, "${fileId}", fileID)
+
+	resolved := make(map[string]string, len(t.Parameters))
+	for k, v := range t.Parameters {
+		resolved[k] = replacer.Replace(v)
+	}
+	return resolved
+}
@@ -0,0 +1,99 @@
+package jobconfig
+
+import "testing"
+
+const validConfig = `
+templates:
+  - name: pdf
+    prefix: uploads/pdf/
+    jobQueue: pdf-queue
+    jobDefinition: pdf-job-def
+    containerOverrides:
+      vcpus: 2
+      memory: 4096
+      env:
+        OCR_ENGINE: tesseract
+    retryStrategy:
+      attempts: 3
+    timeout: 900
+    tags:
+      team: ocr
+    parameters:
+      source: "s3://${bucket}/${key}"
+  - name: default
+    prefix: uploads/
+    jobQueue: default-queue
+    jobDefinition: default-job-def
+`
+
+func TestParseRejectsUnknownFields(t *testing.T) {
+	data := []byte("templates:\n  - name: pdf\n    prefix: uploads/\n    jobQueue: q\n    jobDefinition: d\n    jobQeue: typo\n")
+	if _, err := Parse(data); err == nil {
+		t.Fatal("expected an error for an unknown field, got nil")
+	}
+}
+
+func TestParseRejectsEmptyTemplates(t *testing.T) {
+	if _, err := Parse([]byte("templates: []\n")); err == nil {
+		t.Fatal("expected an error for a config with no templates, got nil")
+	}
+}
+
+func TestParseRejectsMissingRequiredFields(t *testing.T) {
+	data := []byte("templates:\n  - name: pdf\n    prefix: uploads/\n")
+	if _, err := Parse(data); err == nil {
+		t.Fatal("expected an error for a template missing jobQueue/jobDefinition, got nil")
+	}
+}
+
+func TestMatchPicksFirstPrefixMatch(t *testing.T) {
+	cfg, err := Parse([]byte(validConfig))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	tmpl, err := cfg.Match("uploads/pdf/2026/07/27/abc/file.pdf")
+	if err != nil {
+		t.Fatalf("Match: %v", err)
+	}
+	if tmpl.Name != "pdf" {
+		t.Fatalf("expected the pdf template, got %q", tmpl.Name)
+	}
+
+	tmpl, err = cfg.Match("uploads/images/2026/07/27/abc/file.png")
+	if err != nil {
+		t.Fatalf("Match: %v", err)
+	}
+	if tmpl.Name != "default" {
+		t.Fatalf("expected the default template, got %q", tmpl.Name)
+	}
+}
+
+func TestMatchReturnsErrorWhenNoTemplateMatches(t *testing.T) {
+	cfg, err := Parse([]byte(validConfig))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if _, err := cfg.Match("other/2026/07/27/abc/file.pdf"); err == nil {
+		t.Fatal("expected an error when no template's prefix matches, got nil")
+	}
+}
+
+func TestResolveParametersInterpolatesPlaceholders(t *testing.T) {
+	cfg, err := Parse([]byte(validConfig))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	tmpl, err := cfg.Match("uploads/pdf/2026/07/27/abc/file.pdf")
+	if err != nil {
+		t.Fatalf("Match: %v", err)
+	}
+
+	resolved := tmpl.ResolveParameters("my-bucket", "uploads/pdf/2026/07/27/abc/file.pdf", "abc")
+	want := "s3://my-bucket/uploads/pdf/2026/07/27/abc/file.pdf"
+	if resolved["source"] != want {
+		t.Fatalf("expected source=%q, got %q", want, resolved["source"])
+	}
+}
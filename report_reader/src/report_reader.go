@@ -0,0 +1,102 @@
+package main
+
+// report_reader.go serves GET /reports/{jobId}: it reads the durable
+// BatchJobReport that batch_status_reconciliation or dead_job_detector
+// wrote to S3 when the job reached a terminal status (see
+// pkg/batchreport), giving an operator the full audit trail for a
+// completed job after DynamoDB has moved on to its latest state.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"github.com/CaringalML/OCR-Processing-Pipeline-Using-AWS-Batch-Serverless-Architecture/pkg/batchreport"
+	"github.com/CaringalML/OCR-Processing-Pipeline-Using-AWS-Batch-Serverless-Architecture/pkg/blobstore"
+)
+
+// ErrorResponse is the error body for every non-2xx response.
+type ErrorResponse struct {
+	Error     string `json:"error"`
+	Message   string `json:"message"`
+	Timestamp string `json:"timestamp"`
+}
+
+var reportStore blobstore.Store
+
+func init() {
+	reportsBucket := os.Getenv("REPORTS_BUCKET_NAME")
+	if reportsBucket == "" {
+		return
+	}
+
+	var err error
+	reportStore, err = blobstore.New(reportsBucket)
+	if err != nil {
+		log.Fatalf("failed to build report store: %v", err)
+	}
+}
+
+func main() {
+	lambda.Start(handleRequest)
+}
+
+func handleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	headers := map[string]string{
+		"Content-Type":                "application/json",
+		"Access-Control-Allow-Origin": "*",
+	}
+
+	if request.HTTPMethod == "OPTIONS" {
+		return events.APIGatewayProxyResponse{StatusCode: 200, Headers: headers, Body: ""}, nil
+	}
+
+	if reportStore == nil {
+		return createErrorResponse(headers, 500, "Configuration Error", "REPORTS_BUCKET_NAME is not configured")
+	}
+
+	segments := strings.Split(strings.Trim(request.Path, "/"), "/")
+	if request.HTTPMethod != "GET" || len(segments) != 2 || segments[0] != "reports" || segments[1] == "" {
+		return createErrorResponse(headers, 404, "Not Found", fmt.Sprintf("No report route for %s %s", request.HTTPMethod, request.Path))
+	}
+	jobID := segments[1]
+
+	key, err := batchreport.Find(ctx, reportStore, jobID)
+	if err != nil {
+		log.Printf("Report for job %s not found: %v", jobID, err)
+		return createErrorResponse(headers, 404, "Not Found", fmt.Sprintf("No report found for job %s", jobID))
+	}
+
+	reader, err := reportStore.Get(ctx, key)
+	if err != nil {
+		log.Printf("Error reading report %s: %v", key, err)
+		return createErrorResponse(headers, 500, "Internal Error", fmt.Sprintf("Failed to read report: %v", err))
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		log.Printf("Error reading report %s: %v", key, err)
+		return createErrorResponse(headers, 500, "Internal Error", fmt.Sprintf("Failed to read report: %v", err))
+	}
+
+	return events.APIGatewayProxyResponse{StatusCode: 200, Headers: headers, Body: string(data)}, nil
+}
+
+func createErrorResponse(headers map[string]string, statusCode int, errorType, message string) (events.APIGatewayProxyResponse, error) {
+	errorResponse := ErrorResponse{
+		Error:     errorType,
+		Message:   message,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+	responseBody, _ := json.Marshal(errorResponse)
+	return events.APIGatewayProxyResponse{StatusCode: statusCode, Headers: headers, Body: string(responseBody)}, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+// report_ttl_sweeper.go is a scheduled Lambda that deletes BatchJobReport
+// objects (see pkg/batchreport) older than REPORT_RETENTION_DAYS, so the
+// reports/ tree report_reader serves from doesn't grow forever.
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"github.com/CaringalML/OCR-Processing-Pipeline-Using-AWS-Batch-Serverless-Architecture/pkg/batchreport"
+	"github.com/CaringalML/OCR-Processing-Pipeline-Using-AWS-Batch-Serverless-Architecture/pkg/blobstore"
+)
+
+// Response structure
+type Response struct {
+	StatusCode int                    `json:"statusCode"`
+	Body       map[string]interface{} `json:"body"`
+}
+
+var (
+	reportStore         blobstore.Store
+	reportRetentionDays int
+)
+
+func init() {
+	reportsBucket := os.Getenv("REPORTS_BUCKET_NAME")
+	if reportsBucket != "" {
+		var err error
+		reportStore, err = blobstore.New(reportsBucket)
+		if err != nil {
+			log.Fatalf("failed to build report store: %v", err)
+		}
+	}
+
+	reportRetentionDays = 30
+	if retentionStr := os.Getenv("REPORT_RETENTION_DAYS"); retentionStr != "" {
+		if parsed, err := strconv.Atoi(retentionStr); err == nil && parsed > 0 {
+			reportRetentionDays = parsed
+		}
+	}
+}
+
+func main() {
+	lambda.Start(handleRequest)
+}
+
+func handleRequest(ctx context.Context, event interface{}) (Response, error) {
+	log.Printf("Starting report TTL sweep (retention: %d days)", reportRetentionDays)
+
+	if reportStore == nil {
+		log.Printf("ERROR: REPORTS_BUCKET_NAME environment variable not set")
+		return Response{
+			StatusCode: 500,
+			Body: map[string]interface{}{
+				"error": "REPORTS_BUCKET_NAME not configured",
+			},
+		}, nil
+	}
+
+	cutoff := time.Now().UTC().AddDate(0, 0, -reportRetentionDays)
+
+	staleKeys, err := batchreport.ListOlderThan(ctx, reportStore, cutoff)
+	if err != nil {
+		log.Printf("ERROR: Failed to list stale reports: %v", err)
+		return Response{
+			StatusCode: 500,
+			Body: map[string]interface{}{
+				"error": err.Error(),
+			},
+		}, nil
+	}
+
+	deleted := 0
+	var errs []string
+	for _, key := range staleKeys {
+		if err := reportStore.Delete(ctx, key); err != nil {
+			log.Printf("ERROR: Failed to delete stale report %s: %v", key, err)
+			errs = append(errs, err.Error())
+			continue
+		}
+		deleted++
+	}
+
+	log.Printf("Deleted %d of %d stale reports", deleted, len(staleKeys))
+
+	return Response{
+		StatusCode: 200,
+		Body: map[string]interface{}{
+			"message": "Report TTL sweep complete",
+			"found":   len(staleKeys),
+			"deleted": deleted,
+			"errors":  errs,
+		},
+	}, nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// IndexedResult is the document mirrored into OpenSearch for each processed
+// file, combining extracted text with the Comprehend/Textract facets the
+// results-list Lambda's search endpoint filters on.
+type IndexedResult struct {
+	FileID        string   `json:"fileId"`
+	ExtractedText string   `json:"extracted_text"`
+	Language      string   `json:"language,omitempty"`
+	Sentiment     string   `json:"sentiment,omitempty"`
+	EntityTypes   []string `json:"entity_types,omitempty"`
+	HasTables     bool     `json:"has_tables"`
+	HasForms      bool     `json:"has_forms"`
+	UploadDate    string   `json:"upload_date,omitempty"`
+}
+
+var (
+	httpClient  *http.Client
+	indexURL    string
+)
+
+func init() {
+	endpoint := os.Getenv("OPENSEARCH_ENDPOINT")
+	index := os.Getenv("OPENSEARCH_INDEX")
+	if index == "" {
+		index = "ocr-results"
+	}
+
+	if endpoint != "" {
+		indexURL = strings.TrimRight(endpoint, "/") + "/" + index + "/_doc"
+	}
+
+	httpClient = &http.Client{Timeout: 10 * time.Second}
+}
+
+func main() {
+	lambda.Start(handleStreamEvent)
+}
+
+// handleStreamEvent tails the processing-results table's DynamoDB Stream and
+// mirrors each new/updated row into the OpenSearch index so the results-list
+// Lambda's `q` search can query it instead of scanning DynamoDB.
+func handleStreamEvent(ctx context.Context, event events.DynamoDBEvent) error {
+	if indexURL == "" {
+		return fmt.Errorf("OPENSEARCH_ENDPOINT not configured")
+	}
+
+	for _, record := range event.Records {
+		if record.EventName != "INSERT" && record.EventName != "MODIFY" {
+			continue
+		}
+
+		doc, ok := buildIndexedResult(record)
+		if !ok {
+			continue
+		}
+
+		if err := indexDocument(ctx, doc); err != nil {
+			log.Printf("Failed to index result for %s: %v", doc.FileID, err)
+		}
+	}
+
+	return nil
+}
+
+func buildIndexedResult(record events.DynamoDBEventRecord) (IndexedResult, bool) {
+	newImage := record.Change.NewImage
+	if newImage == nil {
+		return IndexedResult{}, false
+	}
+
+	fileIDAttr, ok := newImage["file_id"]
+	if !ok {
+		return IndexedResult{}, false
+	}
+
+	doc := IndexedResult{
+		FileID:        fileIDAttr.String(),
+		ExtractedText: attrString(newImage["extracted_text"]),
+	}
+
+	if comprehend, ok := newImage["comprehend_analysis"]; ok {
+		m := comprehend.Map()
+		doc.Language = attrString(m["language"])
+		if sentiment, ok := m["sentiment"]; ok {
+			doc.Sentiment = attrString(sentiment.Map()["Sentiment"])
+		}
+		if entitySummary, ok := m["entitySummary"]; ok {
+			for entityType := range entitySummary.Map() {
+				doc.EntityTypes = append(doc.EntityTypes, entityType)
+			}
+		}
+	}
+
+	if textractAnalysis, ok := newImage["textract_analysis"]; ok {
+		m := textractAnalysis.Map()
+		_, doc.HasTables = m["tables"]
+		_, doc.HasForms = m["forms"]
+	}
+
+	return doc, true
+}
+
+func attrString(attr events.DynamoDBAttributeValue) string {
+	if attr.DataType() != events.DataTypeString {
+		return ""
+	}
+	return attr.String()
+}
+
+func indexDocument(ctx context.Context, doc IndexedResult) error {
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/%s", indexURL, doc.FileID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("index request failed with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -2,11 +2,14 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-lambda-go/lambda"
@@ -15,8 +18,18 @@ import (
 	"github.com/aws/aws-sdk-go/service/batch"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/sqs"
+
+	"github.com/CaringalML/OCR-Processing-Pipeline-Using-AWS-Batch-Serverless-Architecture/internal/wire"
+	"github.com/CaringalML/OCR-Processing-Pipeline-Using-AWS-Batch-Serverless-Architecture/pkg/batchjob"
+	"github.com/CaringalML/OCR-Processing-Pipeline-Using-AWS-Batch-Serverless-Architecture/pkg/blobstore"
+	"github.com/CaringalML/OCR-Processing-Pipeline-Using-AWS-Batch-Serverless-Architecture/pkg/jobconfig"
 )
 
+// configKey is the fixed object key the job-template config is always
+// written to within CONFIG_BUCKET_NAME, so operators only ever have one
+// path to remember.
+const configKey = "jobs.yaml"
+
 // EventBridge S3 event structure
 type S3EventDetail struct {
 	Bucket struct {
@@ -31,6 +44,32 @@ type EventBridgeEvent struct {
 	Detail S3EventDetail `json:"detail"`
 }
 
+// OCRRequest is the typed payload a wire.Envelope message carries instead
+// of a legacy EventBridge S3 event, letting an upstream Lambda request
+// e.g. a specific language or a priority bump without resurrecting an
+// ad-hoc JSON struct for every new payload shape. It names only FileID,
+// not the S3 location - resolveMessage looks that up via lookupFileLocation.
+// resolveMessage uses only FileID; Language, Priority and PageRange are
+// decoded but not passed on to the Batch job.
+type OCRRequest struct {
+	FileID    string
+	Language  string
+	Priority  int
+	PageRange string
+}
+
+// resolvedMessage is resolveMessage's output: either a bucket/key/fileID
+// ready to route to batchjob.Submit, or skip set with a reason, for a
+// message that's valid but intentionally not processed (e.g. an S3 event
+// outside uploads/).
+type resolvedMessage struct {
+	bucketName string
+	objectKey  string
+	fileID     string
+	skip       bool
+	skipReason string
+}
+
 // Response structure
 type ProcessingResponse struct {
 	StatusCode int                    `json:"statusCode"`
@@ -38,13 +77,15 @@ type ProcessingResponse struct {
 }
 
 var (
-	sqsClient     *sqs.SQS
-	batchClient   *batch.Batch
-	dynamoClient  *dynamodb.DynamoDB
-	queueURL      string
-	jobQueue      string
-	jobDefinition string
-	dynamoTable   string
+	sqsClient    *sqs.SQS
+	batchClient  *batch.Batch
+	dynamoClient *dynamodb.DynamoDB
+	configStore  blobstore.Store
+	queueURL     string
+	dynamoTable  string
+
+	jobConfigMu sync.RWMutex
+	jobConfig   *jobconfig.Config
 )
 
 func init() {
@@ -54,20 +95,94 @@ func init() {
 	dynamoClient = dynamodb.New(sess)
 
 	queueURL = os.Getenv("SQS_QUEUE_URL")
-	jobQueue = os.Getenv("BATCH_JOB_QUEUE")
-	jobDefinition = os.Getenv("BATCH_JOB_DEFINITION")
 	dynamoTable = os.Getenv("DYNAMODB_TABLE")
+
+	configBucket := os.Getenv("CONFIG_BUCKET_NAME")
+	if configBucket == "" {
+		log.Fatalf("CONFIG_BUCKET_NAME environment variable not set")
+	}
+
+	var err error
+	configStore, err = blobstore.New(configBucket)
+	if err != nil {
+		log.Fatalf("failed to build config store: %v", err)
+	}
+
+	if err := reloadJobConfig(context.Background()); err != nil {
+		log.Fatalf("failed to load job config: %v", err)
+	}
 }
 
 func main() {
 	lambda.Start(handleRequest)
 }
 
+// reloadJobConfig fetches and parses jobs.yaml, replacing the in-memory
+// config only if the new one parses and validates cleanly - a bad reload
+// (e.g. an operator's typo) leaves the dispatcher routing on the last-known-
+// good config instead of falling back to routing nothing at all.
+func reloadJobConfig(ctx context.Context) error {
+	reader, err := configStore.Get(ctx, configKey)
+	if err != nil {
+		return fmt.Errorf("failed to read job config: %v", err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return fmt.Errorf("failed to read job config: %v", err)
+	}
+
+	cfg, err := jobconfig.Parse(data)
+	if err != nil {
+		return fmt.Errorf("invalid job config: %v", err)
+	}
+
+	jobConfigMu.Lock()
+	jobConfig = cfg
+	jobConfigMu.Unlock()
+
+	log.Printf("Loaded job config with %d template(s)", len(cfg.Templates))
+	return nil
+}
+
+func currentJobConfig() *jobconfig.Config {
+	jobConfigMu.RLock()
+	defer jobConfigMu.RUnlock()
+	return jobConfig
+}
+
+// isConfigUpdatedEvent reports whether event is an EventBridge event whose
+// "detail-type" is "config.updated". lambda.Start hands an interface{}
+// handler a map[string]interface{} for any JSON event, so this checks the
+// raw map rather than a type assertion against a typed event.
+func isConfigUpdatedEvent(event interface{}) bool {
+	raw, ok := event.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	detailType, _ := raw["detail-type"].(string)
+	return detailType == "config.updated"
+}
+
+// handleRequest serves two triggers: an EventBridge config.updated event,
+// which just reloads jobs.yaml and returns, and everything else, which
+// polls SQS as before. isConfigUpdatedEvent distinguishes them by the
+// EventBridge envelope's own "detail-type" field rather than anything
+// specific to this Lambda's event shape.
 func handleRequest(ctx context.Context, event interface{}) (ProcessingResponse, error) {
+	if isConfigUpdatedEvent(event) {
+		if err := reloadJobConfig(ctx); err != nil {
+			log.Printf("ERROR: %v", err)
+			return ProcessingResponse{StatusCode: 500, Body: map[string]interface{}{"error": err.Error()}}, nil
+		}
+		return ProcessingResponse{StatusCode: 200, Body: map[string]interface{}{"message": "job config reloaded"}}, nil
+	}
+
 	log.Printf("Processing SQS messages for S3 file processing")
 
 	// Validate environment variables
-	if queueURL == "" || jobQueue == "" || jobDefinition == "" || dynamoTable == "" {
+	if queueURL == "" || dynamoTable == "" {
 		log.Printf("ERROR: Missing required environment variables")
 		return ProcessingResponse{
 			StatusCode: 500,
@@ -123,62 +238,51 @@ func handleRequest(ctx context.Context, event interface{}) (ProcessingResponse,
 }
 
 func processMessage(message *sqs.Message) error {
-	// Parse the message body
-	var eventBody EventBridgeEvent
-	if err := json.Unmarshal([]byte(*message.Body), &eventBody); err != nil {
-		log.Printf("Invalid message format: %v", err)
+	resolved, err := resolveMessage(*message.Body)
+	if err != nil {
+		log.Printf("Invalid message: %v", err)
 		return err
 	}
-
-	detail := eventBody.Detail
-	bucketName := detail.Bucket.Name
-	objectKey := detail.Object.Key
-
-	// Skip if not in uploads folder
-	if !strings.HasPrefix(objectKey, "uploads/") {
-		log.Printf("Skipping non-upload file: %s", objectKey)
-		return deleteMessage(*message.ReceiptHandle)
-	}
-
-	// Extract file_id from the key structure
-	// Format: uploads/YYYY/MM/DD/{file_id}/{filename}
-	keyParts := strings.Split(objectKey, "/")
-	if len(keyParts) < 6 {
-		log.Printf("Invalid key structure: %s", objectKey)
+	if resolved.skip {
+		log.Printf("%s", resolved.skipReason)
 		return deleteMessage(*message.ReceiptHandle)
 	}
 
-	fileID := keyParts[4]
+	bucketName, objectKey, fileID := resolved.bucketName, resolved.objectKey, resolved.fileID
 
-	// Submit Batch job
-	jobName := fmt.Sprintf("process-file-%s-%s", fileID, time.Now().Format("20060102150405"))
-
-	submitInput := &batch.SubmitJobInput{
-		JobName:       aws.String(jobName),
-		JobQueue:      aws.String(jobQueue),
-		JobDefinition: aws.String(jobDefinition),
-		Parameters: map[string]*string{
-			"bucket": aws.String(bucketName),
-			"key":    aws.String(objectKey),
-			"fileId": aws.String(fileID),
-		},
-		ContainerOverrides: &batch.ContainerOverrides{
-			Environment: []*batch.KeyValuePair{
-				{Name: aws.String("S3_BUCKET"), Value: aws.String(bucketName)},
-				{Name: aws.String("S3_KEY"), Value: aws.String(objectKey)},
-				{Name: aws.String("FILE_ID"), Value: aws.String(fileID)},
-				{Name: aws.String("DYNAMODB_TABLE"), Value: aws.String(dynamoTable)},
-			},
-		},
+	cfg := currentJobConfig()
+	if cfg == nil {
+		return fmt.Errorf("job config not loaded")
+	}
+	tmpl, err := cfg.Match(objectKey)
+	if err != nil {
+		log.Printf("Failed to route file %s: %v", fileID, err)
+		return err
 	}
 
-	batchResult, err := batchClient.SubmitJob(submitInput)
+	// Submit Batch job using the matched template's queue/definition and
+	// overrides, so a PDF, an image, and an audio file can each land on a
+	// different queue without this dispatcher hardcoding any of them.
+	jobID, jobName, err := batchjob.Submit(batchClient, batchjob.SubmitInput{
+		BucketName:      bucketName,
+		ObjectKey:       objectKey,
+		FileID:          fileID,
+		JobQueue:        tmpl.JobQueue,
+		JobDefinition:   tmpl.JobDefinition,
+		DynamoTable:     dynamoTable,
+		ExtraParameters: tmpl.ResolveParameters(bucketName, objectKey, fileID),
+		ExtraEnv:        tmpl.ContainerOverrides.Env,
+		VCPUs:           tmpl.ContainerOverrides.VCPUs,
+		MemoryMiB:       tmpl.ContainerOverrides.MemoryMiB,
+		RetryAttempts:   tmpl.RetryStrategy.Attempts,
+		TimeoutSeconds:  tmpl.TimeoutSeconds,
+		Tags:            tmpl.Tags,
+	})
 	if err != nil {
 		log.Printf("Failed to submit batch job for file %s: %v", fileID, err)
 		return err
 	}
 
-	jobID := *batchResult.JobId
 	log.Printf("Submitted Batch job %s for file %s", jobID, fileID)
 
 	// Update DynamoDB with job information
@@ -191,6 +295,80 @@ func processMessage(message *sqs.Message) error {
 	return deleteMessage(*message.ReceiptHandle)
 }
 
+// resolveMessage accepts either a legacy EventBridge S3 JSON event or a
+// base64-encoded wire.Envelope (e.g. wrapping an OCRRequest), telling them
+// apart by base64-decoding body and checking wire.IsEnvelope's magic
+// byte - SQS message bodies must be valid UTF-8 text, so a binary
+// envelope has to travel base64-encoded rather than raw. This lets a
+// producer start enqueueing richer typed payloads without this dispatcher
+// needing a second queue or a schema-version env var.
+func resolveMessage(body string) (resolvedMessage, error) {
+	if decoded, err := base64.StdEncoding.DecodeString(body); err == nil && wire.IsEnvelope(decoded) {
+		envelope, err := wire.Decode(decoded)
+		if err != nil {
+			return resolvedMessage{}, fmt.Errorf("failed to decode wire envelope: %v", err)
+		}
+
+		var request OCRRequest
+		if err := envelope.GetData(&request); err != nil {
+			return resolvedMessage{}, fmt.Errorf("failed to decode OCRRequest payload: %v", err)
+		}
+
+		bucketName, objectKey, err := lookupFileLocation(request.FileID)
+		if err != nil {
+			return resolvedMessage{}, err
+		}
+		return resolvedMessage{bucketName: bucketName, objectKey: objectKey, fileID: request.FileID}, nil
+	}
+
+	var eventBody EventBridgeEvent
+	if err := json.Unmarshal([]byte(body), &eventBody); err != nil {
+		return resolvedMessage{}, fmt.Errorf("invalid message format: %v", err)
+	}
+
+	objectKey := eventBody.Detail.Object.Key
+	if !strings.HasPrefix(objectKey, "uploads/") {
+		return resolvedMessage{skip: true, skipReason: fmt.Sprintf("Skipping non-upload file: %s", objectKey)}, nil
+	}
+
+	// Extract file_id from the key structure
+	// Format: uploads/YYYY/MM/DD/{file_id}/{filename}
+	keyParts := strings.Split(objectKey, "/")
+	if len(keyParts) < 6 {
+		return resolvedMessage{skip: true, skipReason: fmt.Sprintf("Invalid key structure: %s", objectKey)}, nil
+	}
+
+	return resolvedMessage{bucketName: eventBody.Detail.Bucket.Name, objectKey: objectKey, fileID: keyParts[4]}, nil
+}
+
+// lookupFileLocation resolves an OCRRequest's FileID to the bucket/key its
+// file was uploaded to, since a wire.Envelope message carries only the
+// file ID, not the S3 location batchjob.Submit needs.
+func lookupFileLocation(fileID string) (bucketName, objectKey string, err error) {
+	queryInput := &dynamodb.QueryInput{
+		TableName:              aws.String(dynamoTable),
+		KeyConditionExpression: aws.String("file_id = :file_id"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":file_id": {S: aws.String(fileID)},
+		},
+		Limit: aws.Int64(1),
+	}
+
+	result, err := dynamoClient.Query(queryInput)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to query file metadata: %v", err)
+	}
+	if len(result.Items) == 0 {
+		return "", "", fmt.Errorf("file metadata not found for file_id: %s", fileID)
+	}
+
+	item := result.Items[0]
+	if item["bucket_name"] == nil || item["s3_key"] == nil {
+		return "", "", fmt.Errorf("file metadata missing bucket_name/s3_key for file_id: %s", fileID)
+	}
+	return *item["bucket_name"].S, *item["s3_key"].S, nil
+}
+
 func updateFileMetadata(fileID, jobID, jobName string) error {
 	// First, query to get the correct upload_timestamp
 	queryInput := &dynamodb.QueryInput{
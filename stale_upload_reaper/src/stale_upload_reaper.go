@@ -0,0 +1,176 @@
+package main
+
+// stale_upload_reaper.go runs on an EventBridge schedule and aborts
+// multipart uploads (see file_uploader/src/multipart_upload.go) that were
+// initiated but never completed or explicitly aborted. Orphaned parts
+// otherwise sit in S3 accruing storage cost indefinitely since nothing else
+// in the pipeline revisits a session once CompleteMultipartUpload hasn't
+// been called.
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// AbortedUpload records one stale session this run aborted.
+type AbortedUpload struct {
+	Key       string `json:"key"`
+	UploadID  string `json:"uploadId"`
+	Initiated string `json:"initiated"`
+	Success   bool   `json:"success"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Response represents the Lambda response structure
+type Response struct {
+	StatusCode int                    `json:"statusCode"`
+	Body       map[string]interface{} `json:"body"`
+}
+
+var (
+	s3Client      *s3.S3
+	bucketName    string
+	staleAgeHours int64
+)
+
+const defaultStaleAgeHours = 24
+
+func init() {
+	sess := session.Must(session.NewSession())
+	s3Client = s3.New(sess)
+
+	bucketName = os.Getenv("UPLOAD_BUCKET_NAME")
+
+	staleAgeHours = defaultStaleAgeHours
+	if ageStr := os.Getenv("STALE_UPLOAD_AGE_HOURS"); ageStr != "" {
+		if parsed, err := strconv.ParseInt(ageStr, 10, 64); err == nil && parsed > 0 {
+			staleAgeHours = parsed
+		}
+	}
+}
+
+func main() {
+	lambda.Start(handleRequest)
+}
+
+func handleRequest(ctx context.Context, event interface{}) (Response, error) {
+	log.Printf("Starting stale multipart upload reaper (bucket: %s, max age: %d hours)", bucketName, staleAgeHours)
+
+	if bucketName == "" {
+		log.Printf("ERROR: UPLOAD_BUCKET_NAME environment variable not set")
+		return Response{
+			StatusCode: 500,
+			Body: map[string]interface{}{
+				"error": "UPLOAD_BUCKET_NAME not configured",
+			},
+		}, nil
+	}
+
+	cutoff := time.Now().UTC().Add(-time.Duration(staleAgeHours) * time.Hour)
+
+	staleUploads, err := listStaleMultipartUploads(cutoff)
+	if err != nil {
+		log.Printf("ERROR: Failed to list multipart uploads: %v", err)
+		return Response{
+			StatusCode: 500,
+			Body: map[string]interface{}{
+				"error": err.Error(),
+			},
+		}, nil
+	}
+
+	if len(staleUploads) == 0 {
+		log.Printf("No stale multipart uploads found")
+		return Response{
+			StatusCode: 200,
+			Body: map[string]interface{}{
+				"message": "No stale multipart uploads found",
+			},
+		}, nil
+	}
+
+	log.Printf("Found %d stale multipart uploads", len(staleUploads))
+
+	var results []AbortedUpload
+	abortedCount := 0
+	for _, upload := range staleUploads {
+		result := abortStaleUpload(upload)
+		if result.Success {
+			abortedCount++
+		}
+		results = append(results, result)
+	}
+
+	log.Printf("Aborted %d/%d stale multipart uploads", abortedCount, len(staleUploads))
+
+	return Response{
+		StatusCode: 200,
+		Body: map[string]interface{}{
+			"message": fmt.Sprintf("Aborted %d of %d stale multipart uploads", abortedCount, len(staleUploads)),
+			"results": results,
+		},
+	}, nil
+}
+
+// listStaleMultipartUploads pages through ListMultipartUploads and returns
+// every in-progress upload initiated before cutoff.
+func listStaleMultipartUploads(cutoff time.Time) ([]*s3.MultipartUpload, error) {
+	var stale []*s3.MultipartUpload
+
+	input := &s3.ListMultipartUploadsInput{
+		Bucket:     aws.String(bucketName),
+		MaxUploads: aws.Int64(1000),
+	}
+
+	for {
+		result, err := s3Client.ListMultipartUploads(input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list multipart uploads: %v", err)
+		}
+
+		for _, upload := range result.Uploads {
+			if upload.Initiated != nil && upload.Initiated.Before(cutoff) {
+				stale = append(stale, upload)
+			}
+		}
+
+		if !aws.BoolValue(result.IsTruncated) {
+			break
+		}
+		input.KeyMarker = result.NextKeyMarker
+		input.UploadIdMarker = result.NextUploadIdMarker
+	}
+
+	return stale, nil
+}
+
+func abortStaleUpload(upload *s3.MultipartUpload) AbortedUpload {
+	key := aws.StringValue(upload.Key)
+	uploadID := aws.StringValue(upload.UploadId)
+	initiated := ""
+	if upload.Initiated != nil {
+		initiated = upload.Initiated.UTC().Format(time.RFC3339)
+	}
+
+	_, err := s3Client.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(bucketName),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadID),
+	})
+	if err != nil {
+		log.Printf("Error aborting stale upload %s (key: %s): %v", uploadID, key, err)
+		return AbortedUpload{Key: key, UploadID: uploadID, Initiated: initiated, Success: false, Error: err.Error()}
+	}
+
+	log.Printf("Aborted stale multipart upload %s (key: %s, initiated: %s)", uploadID, key, initiated)
+	return AbortedUpload{Key: key, UploadID: uploadID, Initiated: initiated, Success: true}
+}
@@ -0,0 +1,183 @@
+package main
+
+// upload_finalizer.go is the other half of the presigned-upload path added
+// in file_uploader/src/presigned_upload.go: that handler writes an
+// "uploaded-pending" FileMetadata row before the client has PUT any bytes,
+// since the object doesn't exist in S3 yet to read a real size/etag from.
+// Once the client's direct PUT lands, this Lambda - invoked off the same S3
+// ObjectCreated EventBridge rule sqs_processor already subscribes to - reads
+// the object head and patches the row with it. The update is conditioned on
+// processing_status still being "uploaded-pending" so it can never race
+// sqs_processor's later "processing" transition and stomp it back.
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// EventBridge S3 event structure
+type S3EventDetail struct {
+	Bucket struct {
+		Name string `json:"name"`
+	} `json:"bucket"`
+	Object struct {
+		Key string `json:"key"`
+	} `json:"object"`
+}
+
+type EventBridgeEvent struct {
+	Detail S3EventDetail `json:"detail"`
+}
+
+// Response structure
+type Response struct {
+	StatusCode int                    `json:"statusCode"`
+	Body       map[string]interface{} `json:"body"`
+}
+
+var (
+	s3Client     *s3.S3
+	dynamoClient *dynamodb.DynamoDB
+	dynamoTable  string
+)
+
+func init() {
+	sess := session.Must(session.NewSession())
+	s3Client = s3.New(sess)
+	dynamoClient = dynamodb.New(sess)
+
+	dynamoTable = os.Getenv("DYNAMODB_TABLE")
+}
+
+func main() {
+	lambda.Start(handleRequest)
+}
+
+func handleRequest(ctx context.Context, event EventBridgeEvent) (Response, error) {
+	if dynamoTable == "" {
+		log.Printf("ERROR: DYNAMODB_TABLE environment variable not set")
+		return Response{
+			StatusCode: 500,
+			Body: map[string]interface{}{
+				"error": "DynamoDB table name not configured",
+			},
+		}, nil
+	}
+
+	bucketName := event.Detail.Bucket.Name
+	objectKey := event.Detail.Object.Key
+
+	// Skip if not in uploads folder
+	if !strings.HasPrefix(objectKey, "uploads/") {
+		log.Printf("Skipping non-upload object: %s", objectKey)
+		return Response{StatusCode: 200, Body: map[string]interface{}{"message": "skipped non-upload object"}}, nil
+	}
+
+	// Extract file_id from the key structure
+	// Format: uploads/YYYY/MM/DD/{file_id}/{filename}
+	keyParts := strings.Split(objectKey, "/")
+	if len(keyParts) < 6 {
+		log.Printf("Invalid key structure: %s", objectKey)
+		return Response{StatusCode: 200, Body: map[string]interface{}{"message": "skipped malformed key"}}, nil
+	}
+	fileID := keyParts[4]
+
+	head, err := s3Client.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectKey),
+	})
+	if err != nil {
+		log.Printf("ERROR: Failed to head object %s: %v", objectKey, err)
+		return Response{StatusCode: 500, Body: map[string]interface{}{"error": err.Error()}}, nil
+	}
+
+	if err := finalizeUpload(fileID, head); err != nil {
+		// Not every upload goes through the presigned path, and one that
+		// already moved past "uploaded-pending" just means some other
+		// path finalized it first - either way this isn't an error worth
+		// retrying the invocation over.
+		log.Printf("Skipping finalize for file %s: %v", fileID, err)
+		return Response{StatusCode: 200, Body: map[string]interface{}{"message": err.Error()}}, nil
+	}
+
+	log.Printf("Finalized presigned upload for file %s (size: %d)", fileID, aws.Int64Value(head.ContentLength))
+	return Response{
+		StatusCode: 200,
+		Body: map[string]interface{}{
+			"message": "finalized",
+			"fileId":  fileID,
+		},
+	}, nil
+}
+
+func finalizeUpload(fileID string, head *s3.HeadObjectOutput) error {
+	uploadTimestamp, err := getUploadTimestamp(fileID)
+	if err != nil {
+		return fmt.Errorf("failed to get upload timestamp: %v", err)
+	}
+
+	updateInput := &dynamodb.UpdateItemInput{
+		TableName: aws.String(dynamoTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"file_id":          {S: aws.String(fileID)},
+			"upload_timestamp": {S: aws.String(uploadTimestamp)},
+		},
+		UpdateExpression: aws.String("SET processing_status = :status, file_size = :size, etag = :etag, last_updated = :updated"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":status":  {S: aws.String("uploaded")},
+			":pending": {S: aws.String("uploaded-pending")},
+			":size":    {N: aws.String(fmt.Sprintf("%d", aws.Int64Value(head.ContentLength)))},
+			":etag":    {S: aws.String(strings.Trim(aws.StringValue(head.ETag), "\""))},
+			":updated": {S: aws.String(time.Now().UTC().Format(time.RFC3339))},
+		},
+		ConditionExpression: aws.String("processing_status = :pending"),
+	}
+
+	_, err = dynamoClient.UpdateItem(updateInput)
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return fmt.Errorf("file %s already progressed past uploaded-pending", fileID)
+		}
+		return fmt.Errorf("failed to update file %s: %v", fileID, err)
+	}
+
+	return nil
+}
+
+func getUploadTimestamp(fileID string) (string, error) {
+	queryInput := &dynamodb.QueryInput{
+		TableName:              aws.String(dynamoTable),
+		KeyConditionExpression: aws.String("file_id = :file_id"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":file_id": {S: aws.String(fileID)},
+		},
+		Limit: aws.Int64(1),
+	}
+
+	result, err := dynamoClient.Query(queryInput)
+	if err != nil {
+		return "", fmt.Errorf("failed to query file metadata: %v", err)
+	}
+
+	if len(result.Items) == 0 {
+		return "", fmt.Errorf("file metadata not found for file_id: %s", fileID)
+	}
+
+	uploadTimestamp := result.Items[0]["upload_timestamp"]
+	if uploadTimestamp.S == nil {
+		return "", fmt.Errorf("upload_timestamp not found for file_id: %s", fileID)
+	}
+
+	return *uploadTimestamp.S, nil
+}
@@ -0,0 +1,154 @@
+package main
+
+// status_fanout handles the DynamoDB Streams-triggered side of this Lambda
+// (see HANDLER_MODE in websocket_handler.go's main()), pushing processing
+// status changes out to subscribed WebSocket connections.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/apigatewaymanagementapi"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// StatusUpdateMessage is pushed to subscribed WebSocket clients whenever
+// processing_status changes on a metadata row.
+type StatusUpdateMessage struct {
+	Type             string `json:"type"`
+	FileID           string `json:"fileId"`
+	ProcessingStatus string `json:"processingStatus"`
+}
+
+func mainFanout() {
+	lambda.Start(handleStreamEvent)
+}
+
+func handleStreamEvent(ctx context.Context, event events.DynamoDBEvent) error {
+	endpoint := os.Getenv("WEBSOCKET_API_ENDPOINT")
+	if endpoint == "" || connectionsTable == "" {
+		return fmt.Errorf("missing required environment variables")
+	}
+
+	sess := session.Must(session.NewSession())
+	apiClient := apigatewaymanagementapi.New(sess, aws.NewConfig().WithEndpoint(endpoint))
+
+	for _, record := range event.Records {
+		if record.EventName != "MODIFY" && record.EventName != "INSERT" {
+			continue
+		}
+
+		fileID, status, ok := extractStatusChange(record)
+		if !ok {
+			continue
+		}
+
+		log.Printf("Fanning out status change for %s: %s", fileID, status)
+
+		subscribers, err := findSubscribers(fileID, status)
+		if err != nil {
+			log.Printf("Failed to look up subscribers for %s: %v", fileID, err)
+			continue
+		}
+
+		message := StatusUpdateMessage{
+			Type:             "processing_status",
+			FileID:           fileID,
+			ProcessingStatus: status,
+		}
+		payload, err := json.Marshal(message)
+		if err != nil {
+			log.Printf("Failed to marshal status message: %v", err)
+			continue
+		}
+
+		for _, conn := range subscribers {
+			pushToConnection(apiClient, conn.ConnectionID, payload)
+		}
+	}
+
+	return nil
+}
+
+// extractStatusChange pulls file_id/processing_status out of a stream record's
+// NewImage, returning ok=false for records that aren't relevant.
+func extractStatusChange(record events.DynamoDBEventRecord) (fileID, status string, ok bool) {
+	newImage := record.Change.NewImage
+	if newImage == nil {
+		return "", "", false
+	}
+
+	fileIDAttr, hasFileID := newImage["file_id"]
+	statusAttr, hasStatus := newImage["processing_status"]
+	if !hasFileID || !hasStatus {
+		return "", "", false
+	}
+
+	return fileIDAttr.String(), statusAttr.String(), true
+}
+
+// findSubscribers returns connections subscribed either to this exact fileId
+// or to "all" files with a matching (or empty) status filter.
+func findSubscribers(fileID, status string) ([]Connection, error) {
+	result, err := dynamoClient.Scan(&dynamodb.ScanInput{
+		TableName: aws.String(connectionsTable),
+		FilterExpression: aws.String("file_id = :fileId OR attribute_not_exists(file_id) OR file_id = :empty"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":fileId": {S: aws.String(fileID)},
+			":empty":  {S: aws.String("")},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var subscribers []Connection
+	for _, item := range result.Items {
+		var conn Connection
+		if err := dynamodbattribute.UnmarshalMap(item, &conn); err != nil {
+			continue
+		}
+		if conn.StatusFilter != "" && conn.StatusFilter != status {
+			continue
+		}
+		subscribers = append(subscribers, conn)
+	}
+
+	return subscribers, nil
+}
+
+// pushToConnection posts data to a connected client, pruning it from the
+// Connections table if the client has gone away (GoneException).
+func pushToConnection(apiClient *apigatewaymanagementapi.ApiGatewayManagementApi, connectionID string, payload []byte) {
+	_, err := apiClient.PostToConnection(&apigatewaymanagementapi.PostToConnectionInput{
+		ConnectionId: aws.String(connectionID),
+		Data:         payload,
+	})
+	if err == nil {
+		return
+	}
+
+	if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == apigatewaymanagementapi.ErrCodeGoneException {
+		log.Printf("Connection %s is gone, pruning", connectionID)
+		if _, delErr := dynamoClient.DeleteItem(&dynamodb.DeleteItemInput{
+			TableName: aws.String(connectionsTable),
+			Key: map[string]*dynamodb.AttributeValue{
+				"connection_id": {S: aws.String(connectionID)},
+			},
+		}); delErr != nil {
+			log.Printf("Failed to prune stale connection %s: %v", connectionID, delErr)
+		}
+		return
+	}
+
+	log.Printf("Failed to post to connection %s: %v", connectionID, err)
+}
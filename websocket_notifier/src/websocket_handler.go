@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Connection represents a single subscribed WebSocket client, stored so the
+// status-fanout Lambda can look up who to push updates to.
+type Connection struct {
+	ConnectionID string `dynamodbav:"connection_id"`
+	FileID       string `dynamodbav:"file_id"`
+	StatusFilter string `dynamodbav:"status_filter"`
+	ConnectedAt  string `dynamodbav:"connected_at"`
+}
+
+// SubscribeRequest is the body of the "subscribe" action message sent by a
+// client after connecting.
+type SubscribeRequest struct {
+	Action       string `json:"action"`
+	FileID       string `json:"fileId"`
+	StatusFilter string `json:"statusFilter"`
+}
+
+var (
+	dynamoClient      *dynamodb.DynamoDB
+	connectionsTable  string
+)
+
+func init() {
+	sess := session.Must(session.NewSession())
+	dynamoClient = dynamodb.New(sess)
+
+	connectionsTable = os.Getenv("CONNECTIONS_TABLE")
+}
+
+// This binary backs two separate Lambda functions that share the Connections
+// table and its client: the WebSocket $connect/$disconnect/subscribe handler,
+// and the DynamoDB Streams fanout handler below in status_fanout.go. Which one
+// runs is selected at deploy time via HANDLER_MODE.
+func main() {
+	if os.Getenv("HANDLER_MODE") == "fanout" {
+		mainFanout()
+		return
+	}
+	lambda.Start(handleRequest)
+}
+
+func handleRequest(ctx context.Context, request events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if connectionsTable == "" {
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Missing required environment variables"}, nil
+	}
+
+	connectionID := request.RequestContext.ConnectionID
+	routeKey := request.RequestContext.RouteKey
+
+	log.Printf("WebSocket event: route=%s connectionId=%s", routeKey, connectionID)
+
+	switch routeKey {
+	case "$connect":
+		return handleConnect(connectionID)
+	case "$disconnect":
+		return handleDisconnect(connectionID)
+	default:
+		return handleMessage(connectionID, request.Body)
+	}
+}
+
+func handleConnect(connectionID string) (events.APIGatewayProxyResponse, error) {
+	item := Connection{
+		ConnectionID: connectionID,
+		ConnectedAt:  time.Now().UTC().Format(time.RFC3339),
+	}
+
+	av, err := marshalConnection(item)
+	if err != nil {
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Failed to marshal connection"}, nil
+	}
+
+	if _, err := dynamoClient.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(connectionsTable),
+		Item:      av,
+	}); err != nil {
+		log.Printf("Failed to record connection %s: %v", connectionID, err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Failed to connect"}, nil
+	}
+
+	return events.APIGatewayProxyResponse{StatusCode: 200, Body: "Connected"}, nil
+}
+
+func handleDisconnect(connectionID string) (events.APIGatewayProxyResponse, error) {
+	if _, err := dynamoClient.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(connectionsTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"connection_id": {S: aws.String(connectionID)},
+		},
+	}); err != nil {
+		log.Printf("Failed to remove connection %s: %v", connectionID, err)
+	}
+
+	return events.APIGatewayProxyResponse{StatusCode: 200, Body: "Disconnected"}, nil
+}
+
+// handleMessage processes the "subscribe" action, recording which fileId (or
+// status filter, for "all files") this connection wants updates for.
+func handleMessage(connectionID, body string) (events.APIGatewayProxyResponse, error) {
+	var subscribeReq SubscribeRequest
+	if err := json.Unmarshal([]byte(body), &subscribeReq); err != nil {
+		return events.APIGatewayProxyResponse{StatusCode: 400, Body: "Invalid message body"}, nil
+	}
+
+	if subscribeReq.Action != "subscribe" {
+		return events.APIGatewayProxyResponse{StatusCode: 400, Body: "Unsupported action"}, nil
+	}
+
+	item := Connection{
+		ConnectionID: connectionID,
+		FileID:       subscribeReq.FileID,
+		StatusFilter: subscribeReq.StatusFilter,
+		ConnectedAt:  time.Now().UTC().Format(time.RFC3339),
+	}
+
+	av, err := marshalConnection(item)
+	if err != nil {
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Failed to marshal subscription"}, nil
+	}
+
+	if _, err := dynamoClient.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(connectionsTable),
+		Item:      av,
+	}); err != nil {
+		log.Printf("Failed to update subscription for %s: %v", connectionID, err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Failed to subscribe"}, nil
+	}
+
+	return events.APIGatewayProxyResponse{StatusCode: 200, Body: "Subscribed"}, nil
+}
+
+func marshalConnection(c Connection) (map[string]*dynamodb.AttributeValue, error) {
+	item := map[string]*dynamodb.AttributeValue{
+		"connection_id": {S: aws.String(c.ConnectionID)},
+		"connected_at":  {S: aws.String(c.ConnectedAt)},
+	}
+	if c.FileID != "" {
+		item["file_id"] = &dynamodb.AttributeValue{S: aws.String(c.FileID)}
+	}
+	if c.StatusFilter != "" {
+		item["status_filter"] = &dynamodb.AttributeValue{S: aws.String(c.StatusFilter)}
+	}
+	return item, nil
+}